@@ -16,9 +16,197 @@ const (
 
 )
 
+// 运行时对象与层级
+const (
+	ObjectSpawn    = int32(20601) + iota // 生成对象（请求及推送，推送包含服务器分配的网络 ID）
+	ObjectDestroy                        // 删除对象及其子对象
+	ObjectReparent                       // 修改对象的父节点
+)
+
+// 对象自定义属性
+const (
+	PropertySet   = int32(20701) + iota // 设置对象的全部自定义属性（替换）
+	PropertyPatch                       // 修改或删除对象的部分自定义属性
+)
+
+// 课程资源包
+const (
+	AssetManifest = int32(20901) + iota // 课程资源清单推送（资源包 ID、版本、哈希、大小和下载地址）
+	AssetCheck                          // 客户端上报已安装的资源包版本
+	AssetStatus                         // 资源包校验结果
+)
+
+// 授课模式场景历史
+const (
+	HistoryUndo       = int32(20801) + iota // 教师撤销最近若干步操作
+	HistoryRedo                             // 教师重做若干步操作
+	CheckpointSave                          // 教师将当前场景保存为命名检查点
+	CheckpointRestore                       // 教师恢复命名检查点
+	HistoryState                            // 历史状态推送（可撤销、重做步数及检查点列表）
+)
+
+// 课程步骤
+const (
+	StepStart    = int32(20101) + iota // 开始步骤
+	StepComplete                       // 完成步骤
+	StepSkip                           // 跳过步骤
+	StepAdvance                        // 教师推进全班步骤（教学模式）
+	StepState                          // 步骤进度推送
+)
+
+// 成绩评估
+const (
+	ScoreReport = int32(20201) + iota // 练习评分结果推送
+)
+
+// 课程脚本
+const (
+	ScriptEvent   = int32(20301) + iota // 客户端向课程脚本发送自定义事件
+	ScriptMessage                       // 课程脚本发出的消息
+)
+
+// 故障注入
+const (
+	FaultInject   = int32(20401) + iota // 教师注入故障（请求及推送）
+	FaultClear                          // 教师清除故障（请求及推送）
+	FaultResponse                       // 学生开始处置故障推送（发给教师）
+)
+
+// 课堂测验与投票
+const (
+	QuizStart   = int32(20501) + iota // 教师发布测验/投票（请求及推送）
+	QuizAnswer                        // 学生提交答案
+	QuizResults                       // 测验实时统计推送（发给教师）
+	QuizClose                         // 教师结束测验，或截止时间到达（请求及推送）
+)
+
+// Version 当前协议版本，消息格式发生不兼容变化时加一
+const Version = 1
+
+// 连接握手
+const (
+	Hello    = int32(10201) + iota // 客户端连接后首先发送：应用版本、协议版本、平台和能力
+	HelloAck                       // 握手成功响应：协商后的协议版本和启用的能力
+)
+
+// 客户端能力
+const (
+	CapBinary  = "binary"  // 服务器以二进制帧发送消息
+	CapDeflate = "deflate" // 服务器压缩发送的消息（需要连接协商了 permessage-deflate）
+	CapAvatars = "avatars" // 接收其他参与者的虚拟形象姿态帧
+)
+
+// 时间同步
+const (
+	TimeSync         = int32(10101) + iota // 时间同步请求
+	TimeSyncResponse                       // 时间同步响应
+)
+
+// 在线状态与花名册
+const (
+	PresenceJoin   = int32(30001) + iota // 参与者加入
+	PresenceLeave                        // 参与者离开
+	PresenceUpdate                       // 参与者状态变化
+	RosterRequest                        // 花名册请求
+	RosterSnapshot                       // 花名册快照
+)
+
+// 教师课堂控制
+const (
+	ClassroomFreeze   = int32(30101) + iota // 冻结/解冻学生交互
+	ClassroomKick                           // 踢出学生
+	ClassroomMute                           // 静音/取消静音学生
+	ClassroomState                          // 课堂控制状态推送
+	FollowMe                                // 开启/关闭跟随模式
+	FollowMeTransform                       // 教师视角变换（跟随模式）
+)
+
+// 学生进度看板
+const (
+	ProgressReport   = int32(30201) + iota // 学生上报练习进度
+	DashboardRequest                       // 教师请求进度看板
+	DashboardUpdate                        // 进度看板推送
+)
+
+// 举手求助
+const (
+	HelpRaise       = int32(30301) + iota // 学生举手/放下手
+	HelpAcknowledge                       // 教师响应求助（可同时观察学生工作区）
+	HelpClear                             // 教师清除求助
+	HelpQueue                             // 求助队列推送
+)
+
+// 文字聊天与公告
+const (
+	ChatAnnouncement = int32(30401) + iota // 教师全体公告（请求及推送）
+	ChatPrivate                            // 学生与教师之间的私信（请求及推送）
+	ChatHistory                            // 聊天记录回放（发给新加入的客户端）
+)
+
+// 语音通话信令
+const (
+	RTCOffer     = int32(30501) + iota // WebRTC offer 转发
+	RTCAnswer                          // WebRTC answer 转发
+	RTCCandidate                       // ICE 候选转发
+	RTCHangup                          // 挂断通知转发
+	VoiceTalk                          // 教师按键讲话开始/结束
+)
+
+// 练习进度保存与恢复
+const (
+	ProgressOffer  = int32(30601) + iota // 询问学生是否继续上次保存的练习进度
+	ProgressResume                       // 学生继续或放弃保存的进度（请求及结果推送）
+	ProgressReset                        // 教师清除学生保存的进度
+)
+
+// 设备遥测
+const (
+	DeviceTelemetry = int32(30701) + iota // 设备上报电量等状态
+)
+
+// 练习模式个人工作区
+const (
+	WorkspaceObserve     = int32(40001) + iota // 教师观察学生工作区
+	WorkspaceSnapshot                          // 工作区快照
+	WorkspaceDemonstrate                       // 教师将学生工作区推送给全班演示
+)
+
+// 分组协作
+const (
+	GroupCreate = int32(40101) + iota // 教师创建分组（手动或自动）
+	GroupMove                         // 教师调整学生所在分组
+	GroupMerge                        // 教师解散分组，全班合并
+	GroupState                        // 分组状态推送
+)
+
+// 虚拟形象姿态同步
+const (
+	AvatarPose  = int32(40201) + iota // 参与者上报头部与双手姿态（高频、有损）
+	AvatarFrame                       // 服务器聚合后的姿态推送
+)
+
+// 同空间定位锚点
+const (
+	AnchorSet     = int32(40301) + iota // 教师设置或重新校准锚点
+	AnchorRequest                       // 客户端请求锚点
+	AnchorState                         // 锚点状态推送
+)
+
+// WebSocket 关闭原因码
+const (
+	CloseDisplaced         = 4001 + iota // 同一设备在新连接登录，旧连接被替换
+	CloseDuplicateRejected               // 同一设备已在线，新连接被拒绝
+	CloseKicked                          // 被教师踢出
+	CloseHelloRequired                   // 未在限定时间内完成握手
+	CloseUpdateRequired                  // 客户端版本过低，需要更新
+	CloseWrongClassroom                  // 设备已绑定到其他教室
+	CloseIncompatible                    // 客户端协议版本高于服务器支持的最高版本
+)
+
 type Message struct {
 	Type       int32       `json:"type"`
 	Code       int16       `json:"code"`
 	DeviceCode string      `json:"deviceCode"`
 	Data       interface{} `json:"data"`
+	ServerTime int64       `json:"serverTime"` // 服务器发送时间（Unix 毫秒）
 }
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"flag"
 	"net/http"
+	"time"
 
+	"xnfz/internal/assets"
+	"xnfz/internal/chat"
 	"xnfz/internal/course"
+	"xnfz/internal/device"
 	"xnfz/internal/session"
 	"xnfz/internal/websocket"
 
@@ -11,21 +16,111 @@ import (
 )
 
 func main() {
+	config := websocket.DefaultConfig()
+	flag.BoolVar(&config.RosterToAll, "roster-all", config.RosterToAll, "向所有客户端推送花名册（默认仅教师和观察者）")
+	flag.DurationVar(&config.IdleThreshold, "idle", config.IdleThreshold, "学生无操作多久后在进度看板中标记为空闲")
+	flag.DurationVar(&config.ScriptTimeout, "script-timeout", config.ScriptTimeout, "课程脚本单次回调的最长执行时间")
+	flag.BoolVar(&config.PrivateChat, "private-chat", config.PrivateChat, "允许学生给教师发私信")
+	flag.IntVar(&config.ChatHistory, "chat-history", config.ChatHistory, "向新加入的客户端回放的聊天消息条数")
+	flag.DurationVar(&config.AvatarInterval, "avatar-interval", config.AvatarInterval, "虚拟形象姿态的最短推送间隔")
+	flag.IntVar(&config.UndoHistory, "undo-history", config.UndoHistory, "授课模式下最多可撤销的操作步数，0 表示不记录")
+	flag.StringVar(&config.AnchorFile, "anchors", config.AnchorFile, "教室空间锚点保存文件，为空时不保存")
+	chatFilter := flag.String("chat-filter", "", "聊天关键词文件（每行一个），为空时不过滤")
+	chatBlock := flag.Bool("chat-block", false, "拒绝包含关键词的聊天消息（默认以 * 替换关键词）")
+	courseDir := flag.String("courses", "courses", "课程定义文件（*.json）所在目录")
+	progressFile := flag.String("progress", "", "练习进度保存文件，为空时只保存在内存中")
+	recordDir := flag.String("records", "", "会话记录（含评分）保存目录，为空时不保存")
+	assetDir := flag.String("assets", "", "课程资源包目录，为空时不提供下载")
+	assetPolicy := flag.String("asset-policy", string(config.AssetPolicy), "资源包版本不一致时的处理策略：warn、reject")
+	flag.BoolVar(&config.RequireHello, "require-hello", config.RequireHello, "要求客户端连接后首先发送 hello 握手消息")
+	flag.DurationVar(&config.HelloTimeout, "hello-timeout", config.HelloTimeout, "等待 hello 握手消息的最长时间")
+	flag.IntVar(&config.Compatibility.MinProtocol, "min-protocol", config.Compatibility.MinProtocol, "接受的最低客户端协议版本")
+	flag.IntVar(&config.Compatibility.MaxProtocol, "max-protocol", config.Compatibility.MaxProtocol, "接受的最高客户端协议版本，不能高于服务器的协议版本")
+	minApp := flag.String("min-app", "", "各平台最低应用版本，如 quest=1.2.0,*=1.0.0")
+	deviceFile := flag.String("devices", "", "设备登记保存文件，为空时只保存在内存中")
+	adminToken := flag.String("admin-token", "", "设备管理接口的访问令牌，为空时不开放管理接口")
+	deviceStale := flag.Duration("device-stale", 7*24*time.Hour, "设备超过多久未连接在管理接口中标记为 stale")
+	flag.StringVar(&config.Classroom, "classroom", config.Classroom, "本服务器所在教室，绑定到其他教室的设备将被拒绝")
+	duplicatePolicy := flag.String("duplicate", string(config.DuplicatePolicy), "重复 deviceCode 处理策略：kick-old、reject-new、allow-multi")
+	flag.Parse()
+
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
+	config.DuplicatePolicy = websocket.DuplicatePolicy(*duplicatePolicy)
+	if !config.DuplicatePolicy.Valid() {
+		logger.Fatal("Invalid duplicate policy", zap.String("policy", *duplicatePolicy))
+	}
+	if !config.Compatibility.Valid() {
+		logger.Fatal("Invalid protocol version range",
+			zap.Int("minProtocol", config.Compatibility.MinProtocol),
+			zap.Int("maxProtocol", config.Compatibility.MaxProtocol))
+	}
+	minAppVersions, err := websocket.ParseMinAppVersions(*minApp)
+	if err != nil {
+		logger.Fatal("Invalid minimum app versions", zap.Error(err))
+	}
+	config.Compatibility.MinAppVersion = minAppVersions
+
+	config.AssetPolicy = websocket.AssetPolicy(*assetPolicy)
+	if !config.AssetPolicy.Valid() {
+		logger.Fatal("Invalid asset policy", zap.String("policy", *assetPolicy))
+	}
+
+	if *chatFilter != "" {
+		words, err := chat.LoadKeywordFile(*chatFilter)
+		if err != nil {
+			logger.Fatal("Load chat filter", zap.Error(err))
+		}
+		config.ChatFilter = chat.NewKeywordFilter(words, *chatBlock)
+		logger.Info("Chat filter loaded", zap.Int("keywords", len(words)))
+	}
+
+	devices, err := device.NewRegistry(*deviceFile, logger)
+	if err != nil {
+		logger.Fatal("Load device registry", zap.Error(err))
+	}
+	config.Devices = devices
+	go devices.Run()
+
 	sessionManager := session.NewManager(logger)
+	if err := sessionManager.SetRecordDir(*recordDir); err != nil {
+		logger.Fatal("Create record directory", zap.Error(err))
+	}
+	if err := sessionManager.SetProgressFile(*progressFile); err != nil {
+		logger.Fatal("Load practice progress", zap.Error(err))
+	}
+	go sessionManager.Run()
+
 	courseManager := course.NewManager(logger)
+	if n, err := courseManager.LoadDir(*courseDir); err != nil {
+		logger.Fatal("Load course definitions", zap.Error(err))
+	} else {
+		logger.Info("Course definitions loaded", zap.Int("count", n), zap.String("dir", *courseDir))
+	}
 
-	hub := websocket.NewHub(sessionManager, courseManager, logger)
+	hub := websocket.NewHub(sessionManager, courseManager, logger, config)
 	go hub.Run()
 
+	if *assetDir != "" {
+		assetServer := assets.NewServer(*assetDir, courseManager, logger)
+		if n := assetServer.Verify(); n > 0 {
+			logger.Warn("Some asset bundles do not match their manifest", zap.Int("count", n))
+		}
+		http.HandleFunc("GET "+assets.Prefix+"{courseId}", assetServer.ServeManifest)
+		http.HandleFunc("GET "+assets.Prefix+"{courseId}/{bundleId}", assetServer.ServeBundle)
+	}
+
+	if *adminToken != "" {
+		device.NewAPI(devices, *adminToken, *deviceStale, logger).Register(http.DefaultServeMux)
+	}
+
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		websocket.ServeWs(hub, w, r)
 	})
 
 	logger.Info("Server is running on :9091")
-	err := http.ListenAndServe(":9091", nil)
+	err = http.ListenAndServe(":9091", nil)
 	if err != nil {
 		logger.Fatal("ListenAndServe: ", zap.Error(err))
 	}
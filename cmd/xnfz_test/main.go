@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 
-	// "fmt"
+	"fmt"
 	"log"
 	"net/url"
 	"os"
@@ -12,6 +12,8 @@ import (
 	"strconv"
 	"time"
 
+	"xnfz/api"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -33,6 +35,11 @@ func main() {
 	}
 	defer c.Close()
 
+	// 连接后首先完成 hello 握手
+	if err := hello(c); err != nil {
+		log.Fatalf("handshake error: %v", err)
+	}
+
 	// 创建心跳定时器
 	ticker := time.NewTicker(3 * time.Second)
 	defer ticker.Stop()
@@ -239,6 +246,38 @@ func main() {
 	}
 }
 
+// hello 发送握手消息并等待服务器的 HelloAck
+func hello(c *websocket.Conn) error {
+	err := c.WriteJSON(protocol.Message{
+		Type: protocol.Hello,
+		Data: map[string]interface{}{
+			"appVersion":      "1.0.0",
+			"protocolVersion": protocol.Version,
+			"platform":        "test",
+			"capabilities":    []string{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	defer c.SetReadDeadline(time.Time{})
+	for {
+		var response protocol.Message
+		if err := c.ReadJSON(&response); err != nil {
+			return err
+		}
+		switch response.Type {
+		case protocol.HelloAck:
+			log.Printf("hello ack: %v", response.Data)
+			return nil
+		case protocol.ErrorMessage:
+			return fmt.Errorf("rejected: %d %v", response.Code, response.Data)
+		}
+	}
+}
+
 func handleObjectManipulation(data interface{}) {
 	// 将 data 转换为 map[string]interface{}
 	dataMap, ok := data.(map[string]interface{})
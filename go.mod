@@ -5,6 +5,7 @@ go 1.22.2
 require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/yuin/gopher-lua v1.1.1
 	go.uber.org/zap v1.27.0
 )
 
@@ -0,0 +1,140 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"xnfz/internal/course"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// Prefix 资源包下载地址的路径前缀
+const Prefix = "/assets/"
+
+// URL 返回资源包的下载地址
+func URL(courseID string, bundleID string) string {
+	return Prefix + url.PathEscape(courseID) + "/" + url.PathEscape(bundleID)
+}
+
+// Manifest 返回课程的资源清单，并填写下载地址
+func Manifest(c *models.Course) []models.AssetBundle {
+	bundles := make([]models.AssetBundle, len(c.Assets))
+	for i, bundle := range c.Assets {
+		bundle.URL = URL(c.ID, bundle.ID)
+		bundle.File = ""
+		bundles[i] = bundle
+	}
+	return bundles
+}
+
+// Server 从本地目录提供课程资源包下载
+type Server struct {
+	dir     string
+	courses *course.Manager
+	logger  *zap.Logger
+}
+
+// NewServer 创建资源包下载服务
+func NewServer(dir string, courses *course.Manager, logger *zap.Logger) *Server {
+	return &Server{dir: dir, courses: courses, logger: logger}
+}
+
+// Verify 检查课程资源包文件是否存在，以及大小和哈希与清单是否一致，返回有问题的资源包数
+func (s *Server) Verify() int {
+	problems := 0
+	for _, c := range s.courses.ListCourses() {
+		for _, bundle := range c.Assets {
+			size, hash, err := fileDigest(filepath.Join(s.dir, bundle.Path()))
+			switch {
+			case err != nil:
+				s.logger.Warn("Asset bundle unavailable",
+					zap.String("courseID", c.ID), zap.String("bundleID", bundle.ID), zap.Error(err))
+			case bundle.Size > 0 && bundle.Size != size:
+				s.logger.Warn("Asset bundle size mismatch",
+					zap.String("courseID", c.ID), zap.String("bundleID", bundle.ID),
+					zap.Int64("manifest", bundle.Size), zap.Int64("file", size))
+			case bundle.Hash != "" && !strings.EqualFold(bundle.Hash, hash):
+				s.logger.Warn("Asset bundle hash mismatch",
+					zap.String("courseID", c.ID), zap.String("bundleID", bundle.ID),
+					zap.String("manifest", bundle.Hash), zap.String("file", hash))
+			default:
+				continue
+			}
+			problems++
+		}
+	}
+	return problems
+}
+
+// fileDigest 计算文件大小和 SHA-256
+func fileDigest(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ServeManifest 处理 GET /assets/{courseId}，返回课程资源清单
+func (s *Server) ServeManifest(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.courses.GetCourse(r.PathValue("courseId"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Manifest(c))
+}
+
+// ServeBundle 处理 GET /assets/{courseId}/{bundleId}，支持 Range 请求断点续传
+func (s *Server) ServeBundle(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.courses.GetCourse(r.PathValue("courseId"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	bundle, ok := c.FindAsset(r.PathValue("bundleId"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, bundle.Path()))
+	if err != nil {
+		s.logger.Error("Failed to open asset bundle",
+			zap.String("courseID", c.ID), zap.String("bundleID", bundle.ID), zap.Error(err))
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Asset-Version", bundle.Version)
+	if bundle.Hash != "" {
+		w.Header().Set("ETag", `"`+bundle.Hash+`"`)
+	}
+	http.ServeContent(w, r, bundle.ID, info.ModTime(), f)
+}
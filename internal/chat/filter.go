@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Filter 聊天内容过滤钩子，返回处理后的文本以及是否允许发送
+type Filter func(senderID string, text string) (string, bool)
+
+// AllowAll 不做任何过滤的默认过滤器
+func AllowAll(_ string, text string) (string, bool) {
+	return text, true
+}
+
+// NewKeywordFilter 创建关键词过滤器，命中的关键词（不区分大小写）替换为等长的 *
+// block 为 true 时直接拒绝包含关键词的消息
+func NewKeywordFilter(keywords []string, block bool) Filter {
+	words := make([]string, 0, len(keywords))
+	for _, word := range keywords {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return func(_ string, text string) (string, bool) {
+		lower := strings.ToLower(text)
+		if len(lower) != len(text) {
+			// 大小写转换改变了字节长度时无法按位置替换，退化为拒绝命中的消息
+			for _, word := range words {
+				if strings.Contains(lower, word) {
+					return text, false
+				}
+			}
+			return text, true
+		}
+
+		masked := []byte(text)
+		hit := false
+		for _, word := range words {
+			// 每次只前进一个字符，同一关键词重叠出现（如 aaa 中的 aa）时全部替换
+			_, step := utf8.DecodeRuneInString(word)
+			for start := 0; ; {
+				i := strings.Index(lower[start:], word)
+				if i < 0 {
+					break
+				}
+				i += start
+				hit = true
+				copy(masked[i:], strings.Repeat("*", len(word)))
+				start = i + step
+			}
+		}
+		if hit && block {
+			return text, false
+		}
+		if !hit {
+			return text, true
+		}
+		return maskRunes(text, masked), true
+	}
+}
+
+// maskRunes 将被替换的多字节字符压缩为单个 *，保证结果是合法的 UTF-8
+func maskRunes(original string, masked []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(original); {
+		_, size := utf8.DecodeRuneInString(original[i:])
+		if masked[i] == '*' && original[i] != '*' {
+			b.WriteByte('*')
+		} else {
+			b.WriteString(original[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// LoadKeywordFile 从文件读取关键词，每行一个，忽略空行和 # 开头的注释
+func LoadKeywordFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,117 @@
+package chat
+
+import "testing"
+
+func TestKeywordFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords []string
+		block    bool
+		text     string
+		want     string
+		wantOK   bool
+	}{
+		{
+			name:     "no keywords",
+			keywords: nil,
+			text:     "hello",
+			want:     "hello",
+			wantOK:   true,
+		},
+		{
+			name:     "no hit",
+			keywords: []string{"bad"},
+			text:     "good work",
+			want:     "good work",
+			wantOK:   true,
+		},
+		{
+			name:     "case insensitive",
+			keywords: []string{" BaD "},
+			text:     "so BAD and bad",
+			want:     "so *** and ***",
+			wantOK:   true,
+		},
+		{
+			name:     "overlapping keywords",
+			keywords: []string{"abc", "bcd"},
+			text:     "xabcdx",
+			want:     "x****x",
+			wantOK:   true,
+		},
+		{
+			name:     "keyword inside another",
+			keywords: []string{"bc", "abcd"},
+			text:     "abcde",
+			want:     "****e",
+			wantOK:   true,
+		},
+		{
+			name:     "overlapping occurrences of one keyword",
+			keywords: []string{"aa"},
+			text:     "aaa b aa",
+			want:     "*** b **",
+			wantOK:   true,
+		},
+		{
+			name:     "multibyte keyword masked per character",
+			keywords: []string{"笨蛋"},
+			text:     "你是笨蛋吗",
+			want:     "你是**吗",
+			wantOK:   true,
+		},
+		{
+			name:     "overlapping multibyte keywords",
+			keywords: []string{"大笨", "笨蛋"},
+			text:     "大笨蛋!",
+			want:     "***!",
+			wantOK:   true,
+		},
+		{
+			name:     "literal asterisk kept",
+			keywords: []string{"bad"},
+			text:     "*bad*",
+			want:     "*****",
+			wantOK:   true,
+		},
+		{
+			name:     "block on hit",
+			keywords: []string{"bad"},
+			block:    true,
+			text:     "bad",
+			want:     "bad",
+			wantOK:   false,
+		},
+		{
+			name:     "block without hit",
+			keywords: []string{"bad"},
+			block:    true,
+			text:     "fine",
+			want:     "fine",
+			wantOK:   true,
+		},
+		{
+			name:     "lowercase changes length, hit rejected",
+			keywords: []string{"stanbul"},
+			text:     "İstanbul",
+			want:     "İstanbul",
+			wantOK:   false,
+		},
+		{
+			name:     "lowercase changes length, no hit",
+			keywords: []string{"bad"},
+			text:     "İstanbul",
+			want:     "İstanbul",
+			wantOK:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewKeywordFilter(tt.keywords, tt.block)("S1", tt.text)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("filter(%q) = (%q, %v), want (%q, %v)", tt.text, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package course
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// definition 课程定义文件的格式
+type definition struct {
+	ID              string                  `json:"id"`
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description"`
+	Mode            models.CourseMode       `json:"mode"`
+	DurationSeconds int                     `json:"durationSeconds"`
+	Steps           []models.Step           `json:"steps"`
+	Scoring         *models.ScoringSpec     `json:"scoring"`
+	Faults          []models.Fault          `json:"faults"`
+	Properties      []models.PropertySpec   `json:"properties"`
+	Objects         []models.ObjectManifest `json:"objects"`
+	Assets          []models.AssetBundle    `json:"assets"`
+	Prefabs         []string                `json:"prefabs"`
+	Script          string                  `json:"script"` // 脚本文件路径，相对于课程定义所在目录
+}
+
+// LoadDir 从目录加载所有 *.json 课程定义，返回加载的课程数
+func (m *Manager) LoadDir(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range files {
+		course, err := loadDefinition(file)
+		if err != nil {
+			return 0, fmt.Errorf("load course %s: %w", file, err)
+		}
+
+		m.mu.Lock()
+		m.courses[course.ID] = course
+		m.mu.Unlock()
+		m.logger.Info("Loaded course definition",
+			zap.String("courseID", course.ID),
+			zap.String("name", course.Name),
+			zap.Int("steps", len(course.Steps)))
+	}
+
+	return len(files), nil
+}
+
+// loadDefinition 读取并校验单个课程定义文件
+func loadDefinition(file string) (*models.Course, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var def definition
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+	if def.ID == "" {
+		return nil, fmt.Errorf("missing course id")
+	}
+
+	seen := make(map[string]bool)
+	for i := range def.Steps {
+		step := &def.Steps[i]
+		if step.ID == "" || seen[step.ID] {
+			return nil, fmt.Errorf("invalid or duplicate step id %q", step.ID)
+		}
+		seen[step.ID] = true
+		if step.Criteria.Type == "" {
+			step.Criteria.Type = models.CriteriaManual
+		}
+	}
+
+	if def.Scoring != nil {
+		for _, rule := range def.Scoring.Rules {
+			switch rule.Type {
+			case models.RuleOrder, models.RuleTimeLimit, models.RuleStepComplete, models.RulePenalty, models.RuleProperty:
+			default:
+				return nil, fmt.Errorf("unknown scoring rule type %q", rule.Type)
+			}
+		}
+	}
+
+	faults := make(map[string]bool)
+	for _, fault := range def.Faults {
+		if fault.ID == "" || faults[fault.ID] {
+			return nil, fmt.Errorf("invalid or duplicate fault id %q", fault.ID)
+		}
+		faults[fault.ID] = true
+	}
+
+	for _, spec := range def.Properties {
+		if spec.Name == "" || !spec.Type.Valid() {
+			return nil, fmt.Errorf("invalid property %q of object %d", spec.Name, spec.ObjectID)
+		}
+		if spec.Type == models.PropertyEnum && len(spec.Values) == 0 {
+			return nil, fmt.Errorf("enum property %q has no values", spec.Name)
+		}
+	}
+
+	objects := make(map[int32]bool)
+	for _, manifest := range def.Objects {
+		if objects[manifest.ID] {
+			return nil, fmt.Errorf("duplicate object %d", manifest.ID)
+		}
+		objects[manifest.ID] = true
+		if (manifest.Min != nil && len(manifest.Min) != 3) || (manifest.Max != nil && len(manifest.Max) != 3) {
+			return nil, fmt.Errorf("object %d bounds must have 3 components", manifest.ID)
+		}
+		for i := range manifest.Min {
+			if manifest.Max != nil && manifest.Min[i] > manifest.Max[i] {
+				return nil, fmt.Errorf("object %d has empty bounds", manifest.ID)
+			}
+		}
+		if manifest.MinScale < 0 || (manifest.MaxScale > 0 && manifest.MinScale > manifest.MaxScale) {
+			return nil, fmt.Errorf("object %d has invalid scale limits", manifest.ID)
+		}
+	}
+
+	bundles := make(map[string]bool)
+	for _, bundle := range def.Assets {
+		if bundle.ID == "" || bundle.Version == "" || bundles[bundle.ID] {
+			return nil, fmt.Errorf("invalid or duplicate asset bundle %q", bundle.ID)
+		}
+		if filepath.IsAbs(bundle.Path()) || !filepath.IsLocal(bundle.Path()) {
+			return nil, fmt.Errorf("asset bundle %q must be inside the asset directory", bundle.ID)
+		}
+		bundles[bundle.ID] = true
+	}
+
+	prefabs := make(map[string]bool)
+	for _, prefab := range def.Prefabs {
+		if prefab == "" || prefabs[prefab] {
+			return nil, fmt.Errorf("invalid or duplicate prefab %q", prefab)
+		}
+		prefabs[prefab] = true
+	}
+
+	var script string
+	if def.Script != "" {
+		path := def.Script
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read script: %w", err)
+		}
+		script = string(source)
+	}
+
+	return &models.Course{
+		ID:          def.ID,
+		Name:        def.Name,
+		Description: def.Description,
+		Mode:        def.Mode,
+		Duration:    time.Duration(def.DurationSeconds) * time.Second,
+		Steps:       def.Steps,
+		Scoring:     def.Scoring,
+		Faults:      def.Faults,
+		Properties:  def.Properties,
+		Objects:     def.Objects,
+		Assets:      def.Assets,
+		Prefabs:     def.Prefabs,
+		Script:      script,
+	}, nil
+}
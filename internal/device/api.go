@@ -0,0 +1,120 @@
+package device
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Prefix 设备管理接口的路径前缀
+const Prefix = "/admin/devices"
+
+// API 设备管理 HTTP 接口，供 IT 管理员查看和维护头显设备
+//
+//	GET    /admin/devices          设备列表，支持 ?stale=true、?classroom=、?school= 过滤
+//	GET    /admin/devices/{code}   单台设备
+//	PUT    /admin/devices/{code}   修改名称、学校、班级或绑定教室（请求体为 Profile）
+//	DELETE /admin/devices/{code}   删除设备登记
+type API struct {
+	registry   *Registry
+	token      string
+	staleAfter time.Duration
+	logger     *zap.Logger
+}
+
+// NewAPI 创建设备管理接口，请求需携带 Authorization: Bearer <token>
+func NewAPI(registry *Registry, token string, staleAfter time.Duration, logger *zap.Logger) *API {
+	return &API{registry: registry, token: token, staleAfter: staleAfter, logger: logger}
+}
+
+// Register 将接口注册到 mux
+func (a *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+Prefix, a.authorized(a.list))
+	mux.HandleFunc("GET "+Prefix+"/{code}", a.authorized(a.get))
+	mux.HandleFunc("PUT "+Prefix+"/{code}", a.authorized(a.update))
+	mux.HandleFunc("DELETE "+Prefix+"/{code}", a.authorized(a.delete))
+}
+
+// authorized 校验管理员令牌
+func (a *API) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			a.logger.Warn("Unauthorized device admin request",
+				zap.String("remoteAddr", r.RemoteAddr),
+				zap.String("path", r.URL.Path))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeJSON 以 JSON 格式返回结果
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// list 返回设备列表
+func (a *API) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	devices := make([]Device, 0)
+	for _, d := range a.registry.List(a.staleAfter) {
+		if query.Get("stale") == "true" && !d.Stale {
+			continue
+		}
+		if classroom := query.Get("classroom"); classroom != "" && d.Classroom != classroom {
+			continue
+		}
+		if school := query.Get("school"); school != "" && d.School != school {
+			continue
+		}
+		devices = append(devices, d)
+	}
+	writeJSON(w, devices)
+}
+
+// get 返回单台设备
+func (a *API) get(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	for _, d := range a.registry.List(a.staleAfter) {
+		if d.Code == code {
+			writeJSON(w, d)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// update 修改设备信息
+func (a *API) update(w http.ResponseWriter, r *http.Request) {
+	var profile Profile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		http.Error(w, "invalid device profile", http.StatusBadRequest)
+		return
+	}
+
+	code := r.PathValue("code")
+	d := a.registry.Update(code, profile)
+	a.logger.Info("Device updated",
+		zap.String("deviceCode", code),
+		zap.String("name", d.Name),
+		zap.String("classroom", d.Classroom))
+	writeJSON(w, d)
+}
+
+// delete 删除设备登记
+func (a *API) delete(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if !a.registry.Delete(code) {
+		http.NotFound(w, r)
+		return
+	}
+	a.logger.Info("Device deleted", zap.String("deviceCode", code))
+	w.WriteHeader(http.StatusNoContent)
+}
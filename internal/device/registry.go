@@ -0,0 +1,264 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// saveInterval 仅连接状态、遥测或在线时间变化时，两次写入登记文件的最短间隔
+const saveInterval = 30 * time.Second
+
+// Device 一台登记的头显设备
+type Device struct {
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	School       string    `json:"school"`
+	Class        string    `json:"class"`
+	Classroom    string    `json:"classroom"` // 绑定的教室，为空表示可以在任意教室使用
+	AppVersion   string    `json:"appVersion"`
+	Platform     string    `json:"platform"`
+	Battery      *int      `json:"battery,omitempty"` // 电量百分比，未上报时为空
+	Charging     bool      `json:"charging"`
+	LastSeen     time.Time `json:"lastSeen"`
+	RegisteredAt time.Time `json:"registeredAt"`
+	Online       bool      `json:"online"`
+	Stale        bool      `json:"stale,omitempty"` // 超过期限未连接，仅在查询结果中填写
+}
+
+// Profile 管理员可以修改的设备信息，为空的字段保持不变
+type Profile struct {
+	Name      *string `json:"name"`
+	School    *string `json:"school"`
+	Class     *string `json:"class"`
+	Classroom *string `json:"classroom"`
+}
+
+// Registry 设备登记表，设备首次连接时自动登记
+type Registry struct {
+	devices  map[string]*Device
+	online   map[string]int // 设备当前的连接数
+	file     string         // 持久化文件，为空时只保存在内存中
+	lastSave time.Time
+	dirty    bool // 有尚未写入文件的变化
+	logger   *zap.Logger
+	mu       sync.RWMutex
+}
+
+// NewRegistry 创建设备登记表，并从文件恢复已登记的设备
+func NewRegistry(file string, logger *zap.Logger) (*Registry, error) {
+	r := &Registry{
+		devices: make(map[string]*Device),
+		online:  make(map[string]int),
+		file:    file,
+		logger:  logger,
+	}
+	if file == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+
+	var devices []*Device
+	if err := json.Unmarshal(raw, &devices); err != nil {
+		return r, err
+	}
+	for _, d := range devices {
+		d.Online = false
+		d.Stale = false
+		r.devices[d.Code] = d
+	}
+	return r, nil
+}
+
+// deviceLocked 返回设备，未登记时自动登记，调用方需持有写锁
+func (r *Registry) deviceLocked(code string, now time.Time) *Device {
+	d, ok := r.devices[code]
+	if !ok {
+		d = &Device{Code: code, Name: code, RegisteredAt: now}
+		r.devices[code] = d
+		r.logger.Info("Device registered", zap.String("deviceCode", code))
+	}
+	return d
+}
+
+// Connected 记录设备连接及客户端版本
+func (r *Registry) Connected(code string, appVersion string, platform string) Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	_, known := r.devices[code]
+	d := r.deviceLocked(code, now)
+	d.LastSeen = now
+	if appVersion != "" {
+		d.AppVersion = appVersion
+		d.Platform = platform
+	}
+	r.online[code]++
+	d.Online = true
+	// 新登记的设备立即写入，其余连接状态变化限制写入频率
+	r.saveLocked(!known)
+	return *d
+}
+
+// Disconnected 记录设备断开连接，已删除登记的设备只减少连接计数
+func (r *Registry) Disconnected(code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	offline := false
+	if r.online[code]--; r.online[code] <= 0 {
+		delete(r.online, code)
+		offline = true
+	}
+	d, ok := r.devices[code]
+	if !ok {
+		return
+	}
+	d.LastSeen = time.Now()
+	if offline {
+		d.Online = false
+	}
+	r.saveLocked(false)
+}
+
+// Telemetry 记录设备上报的电量，未登记（如已被管理员删除）的设备忽略
+func (r *Registry) Telemetry(code string, battery int, charging bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.devices[code]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	d.Battery = &battery
+	d.Charging = charging
+	d.LastSeen = now
+	r.saveLocked(false)
+}
+
+// Get 返回设备信息
+func (r *Registry) Get(code string) (Device, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.devices[code]
+	if !ok {
+		return Device{}, false
+	}
+	return *d, true
+}
+
+// List 返回按设备编码排序的设备列表，超过 staleAfter 未连接的离线设备标记为 stale
+func (r *Registry) List(staleAfter time.Duration) []Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	devices := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		device := *d
+		device.Stale = staleAfter > 0 && !device.Online && now.Sub(device.LastSeen) > staleAfter
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Code < devices[j].Code
+	})
+	return devices
+}
+
+// Update 修改设备信息，设备未登记时先登记（用于提前录入新设备）
+func (r *Registry) Update(code string, profile Profile) Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.deviceLocked(code, time.Now())
+	if profile.Name != nil {
+		d.Name = *profile.Name
+	}
+	if profile.School != nil {
+		d.School = *profile.School
+	}
+	if profile.Class != nil {
+		d.Class = *profile.Class
+	}
+	if profile.Classroom != nil {
+		d.Classroom = *profile.Classroom
+	}
+	r.saveLocked(true)
+	return *d
+}
+
+// Delete 删除设备登记，设备再次连接时会重新登记
+func (r *Registry) Delete(code string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.devices[code]; !ok {
+		return false
+	}
+	delete(r.devices, code)
+	delete(r.online, code)
+	r.saveLocked(true)
+	return true
+}
+
+// Run 定期将限制频率时未写入的变化保存到文件
+func (r *Registry) Run() {
+	if r.file == "" {
+		return
+	}
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.mu.Lock()
+		if r.dirty {
+			r.saveLocked(true)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// saveLocked 将登记表写入文件，调用方需持有写锁
+// force 为 false 时（连接状态、遥测）限制写入频率，未写入的变化由 Run 稍后保存
+func (r *Registry) saveLocked(force bool) {
+	if r.file == "" {
+		return
+	}
+	if !force && time.Since(r.lastSave) < saveInterval {
+		r.dirty = true
+		return
+	}
+
+	devices := make([]*Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Code < devices[j].Code
+	})
+
+	raw, err := json.MarshalIndent(devices, "", "  ")
+	if err == nil {
+		err = os.WriteFile(r.file, raw, 0o644)
+	}
+	if err != nil {
+		r.logger.Error("Failed to save device registry", zap.String("file", r.file), zap.Error(err))
+		return
+	}
+	r.lastSave = time.Now()
+	r.dirty = false
+}
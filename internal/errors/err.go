@@ -8,9 +8,34 @@ type ErrorMessage struct {
 }
 
 var (
-	ErrInvalidData    = ErrorMessage{Code: 10001, Message: "Invalid data"}
-	ErrCourseNotFound = ErrorMessage{Code: 10002, Message: "Course not found"}
-	ErrInternalServer = ErrorMessage{Code: 10003, Message: "Internal server error"}
+	ErrInvalidData        = ErrorMessage{Code: 10001, Message: "Invalid data"}
+	ErrCourseNotFound     = ErrorMessage{Code: 10002, Message: "Course not found"}
+	ErrInternalServer     = ErrorMessage{Code: 10003, Message: "Internal server error"}
+	ErrPermissionDenied   = ErrorMessage{Code: 10004, Message: "Permission denied"}
+	ErrInteractionFrozen  = ErrorMessage{Code: 10005, Message: "Interaction frozen by teacher"}
+	ErrClientNotFound     = ErrorMessage{Code: 10006, Message: "Client not found"}
+	ErrGroupNotFound      = ErrorMessage{Code: 10007, Message: "Group not found"}
+	ErrStepNotFound       = ErrorMessage{Code: 10008, Message: "Step not found"}
+	ErrStepCriteriaNotMet = ErrorMessage{Code: 10009, Message: "Step completion criteria not met"}
+	ErrFaultNotFound      = ErrorMessage{Code: 10010, Message: "Fault not found"}
+	ErrQuizNotFound       = ErrorMessage{Code: 10011, Message: "Quiz not found"}
+	ErrQuizClosed         = ErrorMessage{Code: 10012, Message: "Quiz is closed"}
+	ErrChatRejected       = ErrorMessage{Code: 10013, Message: "Chat message rejected"}
+	ErrObjectNotFound     = ErrorMessage{Code: 10014, Message: "Object not found"}
+	ErrCheckpointNotFound = ErrorMessage{Code: 10015, Message: "Checkpoint not found"}
+	ErrNothingToUndo      = ErrorMessage{Code: 10016, Message: "Nothing to undo or redo"}
+	ErrProgressNotFound   = ErrorMessage{Code: 10017, Message: "No saved progress"}
+	ErrObjectNotAllowed   = ErrorMessage{Code: 10018, Message: "Object or field not allowed in this course"}
+	ErrValueOutOfRange    = ErrorMessage{Code: 10019, Message: "Value out of range"}
+	ErrInvalidValue       = ErrorMessage{Code: 10020, Message: "Invalid numeric value"}
+	ErrAssetMismatch      = ErrorMessage{Code: 10021, Message: "Asset bundles out of date"}
+	ErrHelloRequired      = ErrorMessage{Code: 10022, Message: "Hello message required"}
+	ErrUpdateRequired     = ErrorMessage{Code: 10023, Message: "Client update required"}
+	ErrDeviceNotAllowed   = ErrorMessage{Code: 10024, Message: "Device is bound to another classroom"}
+	ErrMuted              = ErrorMessage{Code: 10025, Message: "Muted by teacher"}
+	ErrKicked             = ErrorMessage{Code: 10026, Message: "Kicked by teacher, try again later"}
+	ErrProtocolTooNew     = ErrorMessage{Code: 10027, Message: "Protocol version not supported by server"}
+	ErrTooManyObjects     = ErrorMessage{Code: 10028, Message: "Too many objects in scene"}
 	// 添加更多错误消息...
 )
 
@@ -23,6 +48,56 @@ func GetErrorMessage(code int16) string {
 		return ErrCourseNotFound.Message
 	case ErrInternalServer.Code:
 		return ErrInternalServer.Message
+	case ErrPermissionDenied.Code:
+		return ErrPermissionDenied.Message
+	case ErrInteractionFrozen.Code:
+		return ErrInteractionFrozen.Message
+	case ErrClientNotFound.Code:
+		return ErrClientNotFound.Message
+	case ErrGroupNotFound.Code:
+		return ErrGroupNotFound.Message
+	case ErrStepNotFound.Code:
+		return ErrStepNotFound.Message
+	case ErrStepCriteriaNotMet.Code:
+		return ErrStepCriteriaNotMet.Message
+	case ErrFaultNotFound.Code:
+		return ErrFaultNotFound.Message
+	case ErrQuizNotFound.Code:
+		return ErrQuizNotFound.Message
+	case ErrQuizClosed.Code:
+		return ErrQuizClosed.Message
+	case ErrChatRejected.Code:
+		return ErrChatRejected.Message
+	case ErrObjectNotFound.Code:
+		return ErrObjectNotFound.Message
+	case ErrCheckpointNotFound.Code:
+		return ErrCheckpointNotFound.Message
+	case ErrNothingToUndo.Code:
+		return ErrNothingToUndo.Message
+	case ErrProgressNotFound.Code:
+		return ErrProgressNotFound.Message
+	case ErrObjectNotAllowed.Code:
+		return ErrObjectNotAllowed.Message
+	case ErrValueOutOfRange.Code:
+		return ErrValueOutOfRange.Message
+	case ErrInvalidValue.Code:
+		return ErrInvalidValue.Message
+	case ErrAssetMismatch.Code:
+		return ErrAssetMismatch.Message
+	case ErrHelloRequired.Code:
+		return ErrHelloRequired.Message
+	case ErrUpdateRequired.Code:
+		return ErrUpdateRequired.Message
+	case ErrDeviceNotAllowed.Code:
+		return ErrDeviceNotAllowed.Message
+	case ErrMuted.Code:
+		return ErrMuted.Message
+	case ErrKicked.Code:
+		return ErrKicked.Message
+	case ErrProtocolTooNew.Code:
+		return ErrProtocolTooNew.Message
+	case ErrTooManyObjects.Code:
+		return ErrTooManyObjects.Message
 	// 添加更多 case...
 	default:
 		return "Unknown error"
@@ -0,0 +1,285 @@
+package scoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"xnfz/pkg/models"
+)
+
+// EventKind 评分引擎消费的事件类型
+type EventKind string
+
+const (
+	EventManipulate   EventKind = "manipulate"
+	EventStepStart    EventKind = "step_start"
+	EventStepComplete EventKind = "step_complete"
+	EventStepSkip     EventKind = "step_skip"
+	EventError        EventKind = "error"
+	EventAdjust       EventKind = "adjust"   // 课程脚本直接加减分
+	EventProperty     EventKind = "property" // 对象自定义属性变化
+)
+
+// Event 会话中发生的一个事件
+type Event struct {
+	Kind     EventKind
+	ObjectID int32
+	StepID   string
+	Points   int         // 仅用于 EventAdjust
+	Detail   string      // 仅用于 EventAdjust
+	Property string      // 仅用于 EventProperty
+	Value    interface{} // 仅用于 EventProperty
+	Time     time.Time
+}
+
+// Item 单条规则的得分明细
+type Item struct {
+	RuleID string `json:"ruleId"`
+	Type   string `json:"type"`
+	Points int    `json:"points"`
+	Max    int    `json:"max"`
+	Detail string `json:"detail"`
+}
+
+// Result 一次练习的评分结果
+type Result struct {
+	Total    int     `json:"total"`
+	Max      int     `json:"max"`
+	Duration float64 `json:"duration"` // 练习用时（秒）
+	Items    []Item  `json:"items"`
+}
+
+// Engine 按课程评分规则汇总单个会话的事件并计算得分
+// 只保存评分所需的统计数据，不保存完整事件流
+type Engine struct {
+	spec            *models.ScoringSpec
+	start           time.Time
+	firstManipulate map[int32]time.Time
+	manipulations   map[int32]int
+	stepStart       map[string]time.Time
+	stepComplete    map[string]time.Time
+	skips           int
+	errors          int
+	adjustments     []Item
+	propertyHits    map[string]time.Time // 属性规则 ID -> 首次达到目标值的时间
+	mu              sync.Mutex
+}
+
+// NewEngine 创建评分引擎，spec 为空时只统计脚本加减分
+func NewEngine(spec *models.ScoringSpec, start time.Time) *Engine {
+	if spec == nil {
+		spec = &models.ScoringSpec{}
+	}
+	return &Engine{
+		spec:            spec,
+		start:           start,
+		firstManipulate: make(map[int32]time.Time),
+		manipulations:   make(map[int32]int),
+		stepStart:       make(map[string]time.Time),
+		stepComplete:    make(map[string]time.Time),
+		propertyHits:    make(map[string]time.Time),
+	}
+}
+
+// Record 记录一个事件
+func (e *Engine) Record(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch ev.Kind {
+	case EventManipulate:
+		if _, ok := e.firstManipulate[ev.ObjectID]; !ok {
+			e.firstManipulate[ev.ObjectID] = ev.Time
+		}
+		e.manipulations[ev.ObjectID]++
+	case EventStepStart:
+		if _, ok := e.stepStart[ev.StepID]; !ok {
+			e.stepStart[ev.StepID] = ev.Time
+		}
+	case EventStepComplete:
+		if _, ok := e.stepComplete[ev.StepID]; !ok {
+			e.stepComplete[ev.StepID] = ev.Time
+		}
+	case EventStepSkip:
+		e.skips++
+	case EventError:
+		e.errors++
+	case EventProperty:
+		for _, rule := range e.spec.Rules {
+			if rule.Type != models.RuleProperty || rule.ObjectID != ev.ObjectID || rule.Property != ev.Property {
+				continue
+			}
+			if _, ok := e.propertyHits[rule.ID]; !ok && sameValue(rule.Value, ev.Value) {
+				e.propertyHits[rule.ID] = ev.Time
+			}
+		}
+	case EventAdjust:
+		e.adjustments = append(e.adjustments, Item{
+			RuleID: "script",
+			Type:   string(EventAdjust),
+			Points: ev.Points,
+			Detail: ev.Detail,
+		})
+	}
+}
+
+// Result 计算截至 end 的评分结果
+func (e *Engine) Result(end time.Time) *Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := &Result{
+		Duration: end.Sub(e.start).Seconds(),
+		Items:    make([]Item, 0, len(e.spec.Rules)),
+	}
+
+	possible := 0
+	for _, rule := range e.spec.Rules {
+		item := e.evaluate(rule, end)
+		if rule.Type != models.RulePenalty {
+			possible += rule.Points
+		}
+		result.Total += item.Points
+		result.Items = append(result.Items, item)
+	}
+	for _, item := range e.adjustments {
+		if item.Points > 0 {
+			possible += item.Points
+		}
+		result.Total += item.Points
+		result.Items = append(result.Items, item)
+	}
+
+	result.Max = e.spec.MaxScore
+	if result.Max <= 0 {
+		result.Max = possible
+	}
+	if result.Total < 0 {
+		result.Total = 0
+	}
+	if result.Total > result.Max {
+		result.Total = result.Max
+	}
+	return result
+}
+
+// evaluate 计算单条规则的得分，调用方需持有锁
+func (e *Engine) evaluate(rule models.ScoringRule, end time.Time) Item {
+	item := Item{RuleID: rule.ID, Type: rule.Type, Max: rule.Points}
+
+	switch rule.Type {
+	case models.RuleOrder:
+		if len(rule.Steps) > 0 {
+			item.Points, item.Detail = e.orderPoints(rule, e.stepTimes(rule.Steps))
+		} else {
+			item.Points, item.Detail = e.orderPoints(rule, e.objectTimes(rule.Objects))
+		}
+
+	case models.RuleTimeLimit:
+		from, to := e.start, end
+		if rule.StepID != "" {
+			started, ok1 := e.stepStart[rule.StepID]
+			completed, ok2 := e.stepComplete[rule.StepID]
+			if !ok1 || !ok2 {
+				item.Detail = "step not completed"
+				return item
+			}
+			from, to = started, completed
+		}
+		elapsed := to.Sub(from)
+		if elapsed <= time.Duration(rule.Seconds)*time.Second {
+			item.Points = rule.Points
+		}
+		item.Detail = fmt.Sprintf("%.1fs of %ds", elapsed.Seconds(), rule.Seconds)
+
+	case models.RuleStepComplete:
+		if _, ok := e.stepComplete[rule.StepID]; ok {
+			item.Points = rule.Points
+			item.Detail = "completed"
+		} else {
+			item.Detail = "not completed"
+		}
+
+	case models.RuleProperty:
+		if _, ok := e.propertyHits[rule.ID]; ok {
+			item.Points = rule.Points
+			item.Detail = "reached"
+		} else {
+			item.Detail = "not reached"
+		}
+
+	case models.RulePenalty:
+		count := e.penaltyCount(rule)
+		deduct := count * rule.Points
+		if rule.MaxPoints > 0 && deduct > rule.MaxPoints {
+			deduct = rule.MaxPoints
+		}
+		item.Points = -deduct
+		item.Max = 0
+		item.Detail = fmt.Sprintf("%d x %s", count, rule.Event)
+	}
+
+	return item
+}
+
+// stepTimes 返回步骤的完成时间，未完成的步骤返回零值
+func (e *Engine) stepTimes(steps []string) []time.Time {
+	times := make([]time.Time, len(steps))
+	for i, step := range steps {
+		times[i] = e.stepComplete[step]
+	}
+	return times
+}
+
+// objectTimes 返回对象的首次操作时间，未操作的对象返回零值
+func (e *Engine) objectTimes(objects []int32) []time.Time {
+	times := make([]time.Time, len(objects))
+	for i, object := range objects {
+		times[i] = e.firstManipulate[object]
+	}
+	return times
+}
+
+// orderPoints 所有环节都已发生且时间不早于前一环节时得分（同一时刻发生视为按顺序）
+func (e *Engine) orderPoints(rule models.ScoringRule, times []time.Time) (int, string) {
+	for i, t := range times {
+		if t.IsZero() {
+			return 0, "incomplete"
+		}
+		if i > 0 && t.Before(times[i-1]) {
+			return 0, "out of order"
+		}
+	}
+	return rule.Points, "in order"
+}
+
+// penaltyCount 返回扣分事件发生的次数
+func (e *Engine) penaltyCount(rule models.ScoringRule) int {
+	switch EventKind(rule.Event) {
+	case EventError:
+		return e.errors
+	case EventStepSkip, "skip":
+		return e.skips
+	case EventManipulate:
+		count := 0
+		for _, object := range rule.Objects {
+			count += e.manipulations[object]
+		}
+		return count
+	}
+	return 0
+}
+
+// sameValue 按 JSON 形式比较两个属性值，使 float64 数组与 JSON 解码得到的数组可以比较
+func sameValue(a, b interface{}) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(x, y)
+}
@@ -0,0 +1,257 @@
+package scoring
+
+import (
+	"testing"
+	"time"
+
+	"xnfz/pkg/models"
+)
+
+// at 距离练习开始 seconds 秒的时间
+func at(start time.Time, seconds float64) time.Time {
+	return start.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+func TestEngineRules(t *testing.T) {
+	type timedEvent struct {
+		at float64 // 距离练习开始的秒数
+		ev Event
+	}
+	manipulate := func(at float64, object int32) timedEvent {
+		return timedEvent{at, Event{Kind: EventManipulate, ObjectID: object}}
+	}
+	stepStart := func(at float64, step string) timedEvent {
+		return timedEvent{at, Event{Kind: EventStepStart, StepID: step}}
+	}
+	stepComplete := func(at float64, step string) timedEvent {
+		return timedEvent{at, Event{Kind: EventStepComplete, StepID: step}}
+	}
+
+	stepOrder := models.ScoringRule{ID: "order", Type: models.RuleOrder, Points: 10, Steps: []string{"open", "check", "close"}}
+	objectOrder := models.ScoringRule{ID: "order", Type: models.RuleOrder, Points: 10, Objects: []int32{1, 2, 3}}
+	totalLimit := models.ScoringRule{ID: "limit", Type: models.RuleTimeLimit, Points: 20, Seconds: 60}
+	stepLimit := models.ScoringRule{ID: "limit", Type: models.RuleTimeLimit, Points: 20, StepID: "check", Seconds: 10}
+
+	tests := []struct {
+		name       string
+		rule       models.ScoringRule
+		events     []timedEvent
+		end        float64
+		wantPoints int
+		wantDetail string
+	}{
+		{
+			name:       "steps in order",
+			rule:       stepOrder,
+			events:     []timedEvent{stepComplete(1, "open"), stepComplete(2, "check"), stepComplete(3, "close")},
+			wantPoints: 10,
+			wantDetail: "in order",
+		},
+		{
+			name:       "steps out of order",
+			rule:       stepOrder,
+			events:     []timedEvent{stepComplete(1, "open"), stepComplete(3, "check"), stepComplete(2, "close")},
+			wantDetail: "out of order",
+		},
+		{
+			name:       "step missing",
+			rule:       stepOrder,
+			events:     []timedEvent{stepComplete(1, "open"), stepComplete(3, "close")},
+			wantDetail: "incomplete",
+		},
+		{
+			name: "repeated completion keeps first time",
+			rule: stepOrder,
+			events: []timedEvent{
+				stepComplete(1, "open"), stepComplete(2, "check"), stepComplete(3, "close"), stepComplete(4, "open"),
+			},
+			wantPoints: 10,
+			wantDetail: "in order",
+		},
+		{
+			name:       "objects in order",
+			rule:       objectOrder,
+			events:     []timedEvent{manipulate(1, 1), manipulate(2, 2), manipulate(3, 3)},
+			wantPoints: 10,
+			wantDetail: "in order",
+		},
+		{
+			name:       "objects manipulated at the same time",
+			rule:       objectOrder,
+			events:     []timedEvent{manipulate(1, 1), manipulate(2, 2), manipulate(2, 3)},
+			wantPoints: 10,
+			wantDetail: "in order",
+		},
+		{
+			name:       "later manipulation does not reorder",
+			rule:       objectOrder,
+			events:     []timedEvent{manipulate(1, 1), manipulate(2, 2), manipulate(3, 3), manipulate(4, 1)},
+			wantPoints: 10,
+			wantDetail: "in order",
+		},
+		{
+			name:       "objects out of order",
+			rule:       objectOrder,
+			events:     []timedEvent{manipulate(1, 2), manipulate(2, 1), manipulate(3, 3)},
+			wantDetail: "out of order",
+		},
+		{
+			name:       "practice within limit",
+			rule:       totalLimit,
+			end:        45,
+			wantPoints: 20,
+			wantDetail: "45.0s of 60s",
+		},
+		{
+			name:       "practice exactly at limit",
+			rule:       totalLimit,
+			end:        60,
+			wantPoints: 20,
+			wantDetail: "60.0s of 60s",
+		},
+		{
+			name:       "practice over limit",
+			rule:       totalLimit,
+			end:        60.5,
+			wantDetail: "60.5s of 60s",
+		},
+		{
+			name:       "step within limit",
+			rule:       stepLimit,
+			events:     []timedEvent{stepStart(5, "check"), stepComplete(14, "check")},
+			end:        100,
+			wantPoints: 20,
+			wantDetail: "9.0s of 10s",
+		},
+		{
+			name:       "step over limit",
+			rule:       stepLimit,
+			events:     []timedEvent{stepStart(5, "check"), stepComplete(16, "check")},
+			end:        20,
+			wantDetail: "11.0s of 10s",
+		},
+		{
+			name:       "step restart keeps first start",
+			rule:       stepLimit,
+			events:     []timedEvent{stepStart(5, "check"), stepStart(12, "check"), stepComplete(16, "check")},
+			end:        20,
+			wantDetail: "11.0s of 10s",
+		},
+		{
+			name:       "step not completed",
+			rule:       stepLimit,
+			events:     []timedEvent{stepStart(5, "check")},
+			end:        6,
+			wantDetail: "step not completed",
+		},
+		{
+			name:       "step never started",
+			rule:       stepLimit,
+			events:     []timedEvent{stepComplete(3, "check")},
+			end:        6,
+			wantDetail: "step not completed",
+		},
+	}
+
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine(&models.ScoringSpec{Rules: []models.ScoringRule{tt.rule}}, start)
+			for _, te := range tt.events {
+				ev := te.ev
+				ev.Time = at(start, te.at)
+				e.Record(ev)
+			}
+
+			result := e.Result(at(start, tt.end))
+			if len(result.Items) != 1 {
+				t.Fatalf("Result() items = %d, want 1", len(result.Items))
+			}
+			item := result.Items[0]
+			if item.Points != tt.wantPoints || item.Detail != tt.wantDetail {
+				t.Errorf("item = (%d, %q), want (%d, %q)", item.Points, item.Detail, tt.wantPoints, tt.wantDetail)
+			}
+			if result.Total != tt.wantPoints || result.Max != tt.rule.Points {
+				t.Errorf("total = %d/%d, want %d/%d", result.Total, result.Max, tt.wantPoints, tt.rule.Points)
+			}
+		})
+	}
+}
+
+func TestEngineTotals(t *testing.T) {
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		spec      *models.ScoringSpec
+		events    []Event
+		wantTotal int
+		wantMax   int
+	}{
+		{
+			name: "penalty capped",
+			spec: &models.ScoringSpec{Rules: []models.ScoringRule{
+				{ID: "done", Type: models.RuleStepComplete, Points: 50, StepID: "open"},
+				{ID: "errors", Type: models.RulePenalty, Points: 5, Event: "error", MaxPoints: 15},
+			}},
+			events: []Event{
+				{Kind: EventStepComplete, StepID: "open"},
+				{Kind: EventError}, {Kind: EventError}, {Kind: EventError}, {Kind: EventError},
+			},
+			wantTotal: 35,
+			wantMax:   50,
+		},
+		{
+			name: "total not below zero",
+			spec: &models.ScoringSpec{Rules: []models.ScoringRule{
+				{ID: "skips", Type: models.RulePenalty, Points: 10, Event: "skip"},
+			}},
+			events:    []Event{{Kind: EventStepSkip}},
+			wantTotal: 0,
+			wantMax:   0,
+		},
+		{
+			name: "script bonus capped at max score",
+			spec: &models.ScoringSpec{MaxScore: 20, Rules: []models.ScoringRule{
+				{ID: "done", Type: models.RuleStepComplete, Points: 20, StepID: "open"},
+			}},
+			events: []Event{
+				{Kind: EventStepComplete, StepID: "open"},
+				{Kind: EventAdjust, Points: 5, Detail: "bonus"},
+			},
+			wantTotal: 20,
+			wantMax:   20,
+		},
+		{
+			name: "property reached with decoded JSON value",
+			spec: &models.ScoringSpec{Rules: []models.ScoringRule{
+				{ID: "valve", Type: models.RuleProperty, Points: 10, ObjectID: 7, Property: "angle", Value: []interface{}{float64(90), float64(0)}},
+			}},
+			events: []Event{
+				{Kind: EventProperty, ObjectID: 7, Property: "angle", Value: []float64{45, 0}},
+				{Kind: EventProperty, ObjectID: 7, Property: "angle", Value: []float64{90, 0}},
+			},
+			wantTotal: 10,
+			wantMax:   10,
+		},
+		{
+			name:      "no spec",
+			events:    []Event{{Kind: EventAdjust, Points: 3}, {Kind: EventAdjust, Points: -1}},
+			wantTotal: 2,
+			wantMax:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine(tt.spec, start)
+			for i, ev := range tt.events {
+				ev.Time = at(start, float64(i+1))
+				e.Record(ev)
+			}
+			result := e.Result(at(start, 30))
+			if result.Total != tt.wantTotal || result.Max != tt.wantMax {
+				t.Errorf("Result() = %d/%d, want %d/%d", result.Total, result.Max, tt.wantTotal, tt.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package script
+
+import (
+	"sort"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Go 与 Lua 值互相转换时的限制，超出部分被丢弃
+const (
+	maxConvertDepth = 16   // 最大嵌套深度
+	maxConvertItems = 4096 // 单次转换最多产生的值（含嵌套）
+)
+
+// converter 一次转换的剩余额度
+type converter struct {
+	remaining int
+}
+
+// toLua 将 JSON 解码得到的 Go 值转换为 Lua 值
+// 超过 maxStringLength 的字符串转换为 nil，值的总数超过 maxConvertItems 后其余部分被丢弃
+func toLua(L *lua.LState, v interface{}) lua.LValue {
+	c := &converter{remaining: maxConvertItems}
+	return c.toLua(L, v, 0)
+}
+
+// fromLua 将 Lua 值转换为可 JSON 序列化的 Go 值，限制与 toLua 相同
+func fromLua(v lua.LValue) interface{} {
+	c := &converter{remaining: maxConvertItems}
+	return c.fromLua(v, 0)
+}
+
+// take 消耗一个值的额度，额度用完时返回 false
+func (c *converter) take() bool {
+	if c.remaining <= 0 {
+		return false
+	}
+	c.remaining--
+	return true
+}
+
+func (c *converter) toLua(L *lua.LState, v interface{}, depth int) lua.LValue {
+	if depth > maxConvertDepth || !c.take() {
+		return lua.LNil
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(val)
+	case float64:
+		return lua.LNumber(val)
+	case int:
+		return lua.LNumber(val)
+	case int32:
+		return lua.LNumber(val)
+	case int64:
+		return lua.LNumber(val)
+	case string:
+		if len(val) > maxStringLength {
+			return lua.LNil
+		}
+		return lua.LString(val)
+	case []float64:
+		t := L.NewTable()
+		for _, item := range val {
+			if !c.take() {
+				break
+			}
+			t.Append(lua.LNumber(item))
+		}
+		return t
+	case []interface{}:
+		t := L.NewTable()
+		for _, item := range val {
+			if c.remaining <= 0 {
+				break
+			}
+			t.Append(c.toLua(L, item, depth+1))
+		}
+		return t
+	case map[string]interface{}:
+		t := L.NewTable()
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if c.remaining <= 0 {
+				break
+			}
+			t.RawSetString(k, c.toLua(L, val[k], depth+1))
+		}
+		return t
+	case map[int32]interface{}:
+		t := L.NewTable()
+		for k, item := range val {
+			if c.remaining <= 0 {
+				break
+			}
+			t.RawSetInt(int(k), c.toLua(L, item, depth+1))
+		}
+		return t
+	}
+	return lua.LNil
+}
+
+// 键为 1..n 连续整数的表转换为数组，其余表转换为以字符串为键的对象
+func (c *converter) fromLua(v lua.LValue, depth int) interface{} {
+	if depth > maxConvertDepth || !c.take() {
+		return nil
+	}
+
+	switch val := v.(type) {
+	case lua.LBool:
+		return bool(val)
+	case lua.LNumber:
+		return float64(val)
+	case lua.LString:
+		return string(val)
+	case *lua.LTable:
+		if n := val.Len(); n > 0 {
+			array := make([]interface{}, 0, min(n, c.remaining))
+			for i := 1; i <= n && c.remaining > 0; i++ {
+				array = append(array, c.fromLua(val.RawGetInt(i), depth+1))
+			}
+			return array
+		}
+		object := make(map[string]interface{})
+		val.ForEach(func(k, item lua.LValue) {
+			if c.remaining > 0 {
+				object[k.String()] = c.fromLua(item, depth+1)
+			}
+		})
+		return object
+	}
+	return nil
+}
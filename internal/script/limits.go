@@ -0,0 +1,348 @@
+package script
+
+import (
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/pm"
+)
+
+// 单个 Lua 虚拟机持有的内存上限
+// 回调执行中的增长由超时和字符串长度上限约束，回调结束后统计虚拟机保留的数据量
+const (
+	maxStringLength  = 64 * 1024 // 拼接、string.rep、string.format、string.gsub 和 table.concat 结果的最大长度
+	maxGsubMatches   = 1024      // string.gsub 单次最多替换的次数
+	maxRetainedBytes = 16 << 20  // 回调结束后虚拟机可达数据的估算上限（字节）
+)
+
+// 估算保留数据量时各类值的固定开销（字节）
+const (
+	tableOverhead    = 64
+	entryOverhead    = 40
+	stringOverhead   = 16
+	functionOverhead = 64
+	upvalueOverhead  = 32
+)
+
+// concatGlobal 替代 .. 运算符的全局函数名
+// 字符串拼接是 Lua 指令而非库函数，编译前将脚本中的 a .. b 改写为对该函数的调用以检查结果长度
+const concatGlobal = "__xnfz_concat"
+
+// rewriteConcat 将语法树中的字符串拼接改写为 concatGlobal 调用
+func rewriteConcat(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		rewriteStmt(stmt)
+	}
+}
+
+// rewriteStmt 改写语句中的表达式
+func rewriteStmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		rewriteExprs(s.Lhs)
+		rewriteExprs(s.Rhs)
+	case *ast.LocalAssignStmt:
+		rewriteExprs(s.Exprs)
+	case *ast.FuncCallStmt:
+		s.Expr = rewriteExpr(s.Expr)
+	case *ast.DoBlockStmt:
+		rewriteConcat(s.Stmts)
+	case *ast.WhileStmt:
+		s.Condition = rewriteExpr(s.Condition)
+		rewriteConcat(s.Stmts)
+	case *ast.RepeatStmt:
+		s.Condition = rewriteExpr(s.Condition)
+		rewriteConcat(s.Stmts)
+	case *ast.IfStmt:
+		s.Condition = rewriteExpr(s.Condition)
+		rewriteConcat(s.Then)
+		rewriteConcat(s.Else)
+	case *ast.NumberForStmt:
+		s.Init = rewriteExpr(s.Init)
+		s.Limit = rewriteExpr(s.Limit)
+		s.Step = rewriteExpr(s.Step)
+		rewriteConcat(s.Stmts)
+	case *ast.GenericForStmt:
+		rewriteExprs(s.Exprs)
+		rewriteConcat(s.Stmts)
+	case *ast.FuncDefStmt:
+		rewriteExpr(s.Func)
+	case *ast.ReturnStmt:
+		rewriteExprs(s.Exprs)
+	}
+}
+
+// rewriteExprs 原地改写表达式列表
+func rewriteExprs(exprs []ast.Expr) {
+	for i, expr := range exprs {
+		exprs[i] = rewriteExpr(expr)
+	}
+}
+
+// rewriteExpr 改写表达式，返回替换后的表达式
+func rewriteExpr(expr ast.Expr) ast.Expr {
+	switch ex := expr.(type) {
+	case *ast.StringConcatOpExpr:
+		call := &ast.FuncCallExpr{
+			Func: &ast.IdentExpr{Value: concatGlobal},
+			Args: []ast.Expr{rewriteExpr(ex.Lhs), rewriteExpr(ex.Rhs)},
+		}
+		call.SetLine(ex.Line())
+		call.SetLastLine(ex.LastLine())
+		call.Func.SetLine(ex.Line())
+		call.Func.SetLastLine(ex.LastLine())
+		return call
+	case *ast.AttrGetExpr:
+		ex.Object = rewriteExpr(ex.Object)
+		ex.Key = rewriteExpr(ex.Key)
+	case *ast.TableExpr:
+		for _, field := range ex.Fields {
+			field.Key = rewriteExpr(field.Key)
+			field.Value = rewriteExpr(field.Value)
+		}
+	case *ast.FuncCallExpr:
+		ex.Func = rewriteExpr(ex.Func)
+		ex.Receiver = rewriteExpr(ex.Receiver)
+		rewriteExprs(ex.Args)
+	case *ast.LogicalOpExpr:
+		ex.Lhs = rewriteExpr(ex.Lhs)
+		ex.Rhs = rewriteExpr(ex.Rhs)
+	case *ast.RelationalOpExpr:
+		ex.Lhs = rewriteExpr(ex.Lhs)
+		ex.Rhs = rewriteExpr(ex.Rhs)
+	case *ast.ArithmeticOpExpr:
+		ex.Lhs = rewriteExpr(ex.Lhs)
+		ex.Rhs = rewriteExpr(ex.Rhs)
+	case *ast.UnaryMinusOpExpr:
+		ex.Expr = rewriteExpr(ex.Expr)
+	case *ast.UnaryNotOpExpr:
+		ex.Expr = rewriteExpr(ex.Expr)
+	case *ast.UnaryLenOpExpr:
+		ex.Expr = rewriteExpr(ex.Expr)
+	case *ast.FunctionExpr:
+		rewriteConcat(ex.Stmts)
+	}
+	return expr
+}
+
+// luaConcat 限制结果长度的 .. 运算，非字符串或数字的操作数按 __concat 元方法处理
+func luaConcat(L *lua.LState) int {
+	lhs, rhs := L.Get(1), L.Get(2)
+	if lua.LVCanConvToString(lhs) && lua.LVCanConvToString(rhs) {
+		a, b := lua.LVAsString(lhs), lua.LVAsString(rhs)
+		if len(a)+len(b) > maxStringLength {
+			L.RaiseError("string concatenation result too large")
+			return 0
+		}
+		L.Push(lua.LString(a + b))
+		return 1
+	}
+
+	op := L.GetMetaField(lhs, "__concat")
+	if op == lua.LNil {
+		op = L.GetMetaField(rhs, "__concat")
+	}
+	if op.Type() != lua.LTFunction {
+		L.RaiseError("cannot perform concat operation between %v and %v", lhs.Type().String(), rhs.Type().String())
+		return 0
+	}
+	L.Push(op)
+	L.Push(lhs)
+	L.Push(rhs)
+	L.Call(2, 1)
+	return 1
+}
+
+// limitLibrary 用限制结果长度的版本替换可以放大字符串的库函数
+func (r *Runtime) limitLibrary() {
+	L := r.state
+	L.SetGlobal(concatGlobal, L.NewFunction(luaConcat))
+
+	if str, ok := L.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		str.RawSetString("rep", L.NewFunction(r.luaStringRep))
+		str.RawSetString("format", limitedCall(L, str.RawGetString("format"), formatBound))
+		str.RawSetString("gsub", L.NewFunction(luaStringGsub(str.RawGetString("gsub"))))
+	}
+	if tbl, ok := L.GetGlobal(lua.TabLibName).(*lua.LTable); ok {
+		tbl.RawSetString("concat", limitedCall(L, tbl.RawGetString("concat"), concatBound))
+	}
+}
+
+// limitedCall 调用原库函数前用 bound 估算结果长度，超过上限时报错
+func limitedCall(L *lua.LState, original lua.LValue, bound func(L *lua.LState) int) *lua.LFunction {
+	return L.NewFunction(func(L *lua.LState) int {
+		if bound(L) > maxStringLength {
+			L.RaiseError("string result too large")
+			return 0
+		}
+		top := L.GetTop()
+		L.Insert(original, 1)
+		L.Call(top, lua.MultRet)
+		return L.GetTop()
+	})
+}
+
+// formatBound 估算 string.format 结果长度的上限：格式串、所有参数以及每个格式符的宽度和精度
+func formatBound(L *lua.LState) int {
+	format := L.CheckString(1)
+	total := len(format)
+	for i := 2; i <= L.GetTop(); i++ {
+		total += len(L.Get(i).String())
+	}
+	for rest := format; ; {
+		i := strings.IndexByte(rest, '%')
+		if i < 0 || i+1 >= len(rest) {
+			break
+		}
+		rest = rest[i+1:]
+		number := 0
+		for len(rest) > 0 && (rest[0] >= '0' && rest[0] <= '9' || strings.IndexByte("-+ #.", rest[0]) >= 0) {
+			if rest[0] >= '0' && rest[0] <= '9' {
+				number = min(number*10+int(rest[0]-'0'), maxStringLength+1)
+			} else {
+				total += number
+				number = 0
+			}
+			rest = rest[1:]
+		}
+		total += number
+		if len(rest) > 0 {
+			rest = rest[1:]
+		}
+	}
+	return total
+}
+
+// concatBound 返回 table.concat 结果的长度
+func concatBound(L *lua.LState) int {
+	tbl := L.CheckTable(1)
+	sep := L.OptString(2, "")
+	n := tbl.Len()
+	i, j := max(L.OptInt(3, 1), 1), min(L.OptInt(4, n), n)
+	total := 0
+	for k := i; k <= j && total <= maxStringLength; k++ {
+		total += len(lua.LVAsString(tbl.RawGetInt(k)))
+		if k < j {
+			total += len(sep)
+		}
+	}
+	return total
+}
+
+// luaStringGsub 限制替换次数和结果长度的 string.gsub
+// 替换为字符串时按捕获展开的最大长度预先估算；替换为表或函数时累计每次的替换结果
+func luaStringGsub(original lua.LValue) lua.LGFunction {
+	return func(L *lua.LState) int {
+		str := L.CheckString(1)
+		pattern := L.CheckString(2)
+		L.CheckTypes(3, lua.LTString, lua.LTTable, lua.LTFunction)
+		repl := L.Get(3)
+		limit := L.OptInt(4, -1)
+		if limit < 0 || limit > maxGsubMatches {
+			limit = maxGsubMatches + 1
+		}
+
+		matches, err := pm.Find(pattern, []byte(str), 0, limit)
+		if err != nil {
+			L.RaiseError(err.Error())
+			return 0
+		}
+		if len(matches) > maxGsubMatches {
+			L.RaiseError("string.gsub too many replacements")
+			return 0
+		}
+
+		switch lv := repl.(type) {
+		case lua.LString:
+			// %0-%9 最多展开为整个字符串
+			each := len(lv) + strings.Count(string(lv), "%")*len(str)
+			if len(str)+len(matches)*each > maxStringLength {
+				L.RaiseError("string.gsub result too large")
+				return 0
+			}
+		case *lua.LTable:
+			repl = replacementCounter(L, func(L *lua.LState) lua.LValue {
+				return L.GetTable(lv, L.Get(1))
+			}, len(str))
+		case *lua.LFunction:
+			repl = replacementCounter(L, func(L *lua.LState) lua.LValue {
+				n := L.GetTop()
+				L.Push(lv)
+				for i := 1; i <= n; i++ {
+					L.Push(L.Get(i))
+				}
+				L.Call(n, 1)
+				return L.Get(-1)
+			}, len(str))
+		}
+
+		L.Push(original)
+		L.Push(lua.LString(str))
+		L.Push(lua.LString(pattern))
+		L.Push(repl)
+		L.Push(lua.LNumber(len(matches)))
+		L.Call(4, 2)
+		return 2
+	}
+}
+
+// replacementCounter 包装 string.gsub 的表或函数替换，累计替换结果长度超过上限时报错
+func replacementCounter(L *lua.LState, lookup func(L *lua.LState) lua.LValue, base int) *lua.LFunction {
+	total := base
+	return L.NewFunction(func(L *lua.LState) int {
+		value := lookup(L)
+		if !lua.LVIsFalse(value) {
+			if total += len(lua.LVAsString(value)); total > maxStringLength {
+				L.RaiseError("string.gsub result too large")
+				return 0
+			}
+		}
+		L.Push(value)
+		return 1
+	})
+}
+
+// retainedSize 估算虚拟机中可达数据（全局变量、注册表、闭包上值和元表）占用的内存
+// 重复引用的同一字符串按引用次数计入，结果偏大；超过 limit 后停止统计
+func retainedSize(L *lua.LState, limit int) int {
+	size := 0
+	seen := make(map[lua.LValue]bool)
+	pending := []lua.LValue{L.G.Global, L.G.Registry, L.GetMetatable(lua.LString(""))}
+	for len(pending) > 0 && size <= limit {
+		value := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		switch v := value.(type) {
+		case lua.LString:
+			size += stringOverhead + len(v)
+		case *lua.LTable:
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			size += tableOverhead
+			pending = append(pending, v.Metatable)
+			v.ForEach(func(key, item lua.LValue) {
+				size += entryOverhead
+				pending = append(pending, key, item)
+			})
+		case *lua.LFunction:
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			size += functionOverhead
+			if v.Env != nil {
+				pending = append(pending, v.Env)
+			}
+			for _, upvalue := range v.Upvalues {
+				if upvalue != nil {
+					size += upvalueOverhead
+					pending = append(pending, upvalue.Value())
+				}
+			}
+		}
+	}
+	return size
+}
@@ -0,0 +1,310 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+	"go.uber.org/zap"
+)
+
+// 沙箱资源限制
+const (
+	callStackSize   = 64       // Lua 调用栈深度上限
+	registrySize    = 1024 * 4 // Lua 数据栈初始大小
+	registryMaxSize = 1024 * 64
+	maxEmitsPerCall = 32 // 单次回调最多发出的消息数
+	maxFailures     = 5  // 连续失败多少次后停用脚本
+)
+
+// errMemoryLimit 虚拟机保留的数据超过上限
+var errMemoryLimit = errors.New("script memory limit exceeded")
+
+// 脚本可以定义的回调函数
+const (
+	HookStart        = "on_start"        // on_start()
+	HookJoin         = "on_join"         // on_join(device)
+	HookLeave        = "on_leave"        // on_leave(device)
+	HookManipulation = "on_manipulation" // on_manipulation(device, objectId, data)
+	HookStep         = "on_step"         // on_step(device, kind, stepId)
+	HookMessage      = "on_message"      // on_message(device, name, data)
+	HookFault        = "on_fault"        // on_fault(device, faultId, params)
+	HookProperty     = "on_property"     // on_property(device, objectId, name, value)
+)
+
+// Host 脚本可以调用的服务器能力，由 websocket.Hub 实现
+type Host interface {
+	// Emit 向 target 指定的设备（为空时为全体）发送脚本消息
+	Emit(name string, data interface{}, target string)
+	// SetObject 修改设备所在场景（为空时为共享场景）中对象的状态并广播
+	SetObject(deviceCode string, objectID int32, data interface{})
+	// AddScore 为学生的当前会话增减分数
+	AddScore(deviceCode string, points int, reason string)
+}
+
+// Runtime 单个课程脚本的沙箱运行时，回调串行执行
+type Runtime struct {
+	courseID string
+	proto    *lua.FunctionProto // 改写并编译后的脚本，虚拟机重建时重新执行
+	state    *lua.LState
+	host     Host
+	timeout  time.Duration
+	logger   *zap.Logger
+	emits    int
+	failures int
+	disabled bool
+	mu       sync.Mutex
+}
+
+// NewRuntime 在沙箱中加载课程脚本，每次回调的执行时间不超过 timeout
+func NewRuntime(courseID string, source string, host Host, timeout time.Duration, logger *zap.Logger) (*Runtime, error) {
+	chunk, err := parse.Parse(strings.NewReader(source), "<string>")
+	if err != nil {
+		return nil, fmt.Errorf("compile script: %w", err)
+	}
+	rewriteConcat(chunk)
+	proto, err := lua.Compile(chunk, "<string>")
+	if err != nil {
+		return nil, fmt.Errorf("compile script: %w", err)
+	}
+
+	r := &Runtime{
+		courseID: courseID,
+		proto:    proto,
+		host:     host,
+		timeout:  timeout,
+		logger:   logger.With(zap.String("courseID", courseID)),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("run script: %w", err)
+	}
+	return r, nil
+}
+
+// load 创建新的虚拟机并执行脚本，调用方需持有锁
+func (r *Runtime) load() error {
+	r.state = lua.NewState(lua.Options{
+		CallStackSize:       callStackSize,
+		RegistrySize:        registrySize,
+		RegistryMaxSize:     registryMaxSize,
+		SkipOpenLibs:        true,
+		MinimizeStackMemory: true,
+	})
+	r.openSandbox()
+
+	err := r.call(r.state.NewFunctionFromProto(r.proto))
+	if err == nil {
+		err = r.checkRetained()
+	}
+	if err != nil {
+		r.state.Close()
+		r.state = nil
+	}
+	return err
+}
+
+// checkRetained 检查虚拟机保留的数据量，调用方需持有锁
+func (r *Runtime) checkRetained() error {
+	if size := retainedSize(r.state, maxRetainedBytes); size > maxRetainedBytes {
+		return fmt.Errorf("%w: state holds more than %d bytes", errMemoryLimit, maxRetainedBytes)
+	}
+	return nil
+}
+
+// openSandbox 只开放基础、字符串、表和数学库，移除可访问文件系统或加载代码的函数，并限制字符串结果长度
+func (r *Runtime) openSandbox() {
+	L := r.state
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage", "getfenv", "setfenv"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	r.limitLibrary()
+	L.SetGlobal("print", L.NewFunction(r.luaLog))
+
+	api := L.NewTable()
+	api.RawSetString("emit", L.NewFunction(r.luaEmit))
+	api.RawSetString("set_object", L.NewFunction(r.luaSetObject))
+	api.RawSetString("add_score", L.NewFunction(r.luaAddScore))
+	api.RawSetString("log", L.NewFunction(r.luaLog))
+	api.RawSetString("now", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(time.Now().UnixMilli()))
+		return 1
+	}))
+	L.SetGlobal("xnfz", api)
+}
+
+// call 在超时限制下执行 Lua 函数，调用方需持有锁
+func (r *Runtime) call(fn *lua.LFunction, args ...lua.LValue) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	r.emits = 0
+	r.state.SetContext(ctx)
+	defer r.state.RemoveContext()
+
+	err := r.state.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, args...)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("script timed out after %s: %w", r.timeout, err)
+	}
+	return err
+}
+
+// Invoke 调用脚本中定义的回调，未定义的回调会被忽略
+// 回调后虚拟机保留的数据超过上限时重建虚拟机（脚本的全局状态丢失），计为一次失败；
+// 连续失败达到上限后脚本被停用
+func (r *Runtime) Invoke(hook string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.disabled || r.state == nil {
+		return
+	}
+	fn, ok := r.state.GetGlobal(hook).(*lua.LFunction)
+	if !ok {
+		return
+	}
+
+	values := make([]lua.LValue, len(args))
+	for i, arg := range args {
+		values[i] = toLua(r.state, arg)
+	}
+
+	err := r.call(fn, values...)
+	if memErr := r.checkRetained(); memErr != nil {
+		r.reset(hook, memErr)
+		return
+	}
+	if err != nil {
+		r.failures++
+		r.logger.Error("Script hook failed", zap.String("hook", hook), zap.Int("failures", r.failures), zap.Error(err))
+		if r.failures >= maxFailures {
+			r.disabled = true
+			r.logger.Error("Script disabled after repeated failures")
+		}
+		return
+	}
+	r.failures = 0
+}
+
+// reset 释放保留数据过多的虚拟机并重新加载脚本，调用方需持有锁
+func (r *Runtime) reset(hook string, cause error) {
+	r.failures++
+	r.logger.Error("Script state reset after exceeding memory limit",
+		zap.String("hook", hook), zap.Int("failures", r.failures), zap.Error(cause))
+	r.state.Close()
+	r.state = nil
+
+	if r.failures >= maxFailures {
+		r.disabled = true
+		r.logger.Error("Script disabled after repeated failures")
+		return
+	}
+	if err := r.load(); err != nil {
+		r.disabled = true
+		r.logger.Error("Script disabled after failing to reload", zap.Error(err))
+	}
+}
+
+// CourseID 返回脚本所属的课程
+func (r *Runtime) CourseID() string {
+	return r.courseID
+}
+
+// Close 释放 Lua 虚拟机
+func (r *Runtime) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != nil {
+		r.state.Close()
+		r.state = nil
+	}
+}
+
+// luaEmit xnfz.emit(name, data[, target])
+func (r *Runtime) luaEmit(L *lua.LState) int {
+	name := L.CheckString(1)
+	data := fromLua(L.Get(2))
+	target := L.OptString(3, "")
+
+	r.emits++
+	if r.emits > maxEmitsPerCall {
+		L.RaiseError("too many messages emitted in one call")
+		return 0
+	}
+	r.host.Emit(name, data, target)
+	return 0
+}
+
+// luaSetObject xnfz.set_object(objectId, data[, device])
+func (r *Runtime) luaSetObject(L *lua.LState) int {
+	objectID := L.CheckInt(1)
+	data := fromLua(L.CheckAny(2))
+	device := L.OptString(3, "")
+	r.host.SetObject(device, int32(objectID), data)
+	return 0
+}
+
+// luaAddScore xnfz.add_score(device, points[, reason])
+func (r *Runtime) luaAddScore(L *lua.LState) int {
+	device := L.CheckString(1)
+	points := L.CheckInt(2)
+	reason := L.OptString(3, "script")
+	r.host.AddScore(device, points, reason)
+	return 0
+}
+
+// luaLog xnfz.log(...) / print(...)
+func (r *Runtime) luaLog(L *lua.LState) int {
+	parts := make([]interface{}, 0, L.GetTop())
+	for i := 1; i <= L.GetTop(); i++ {
+		parts = append(parts, L.Get(i).String())
+	}
+	r.logger.Info("Script log", zap.String("message", fmt.Sprint(parts...)))
+	return 0
+}
+
+// luaStringRep 限制结果长度的 string.rep
+func (r *Runtime) luaStringRep(L *lua.LState) int {
+	s := L.CheckString(1)
+	n := L.CheckInt(2)
+	if n <= 0 || len(s) == 0 {
+		L.Push(lua.LString(""))
+		return 1
+	}
+	if n > maxStringLength/len(s) {
+		L.RaiseError("string.rep result too large")
+		return 0
+	}
+	out := make([]byte, 0, len(s)*n)
+	for i := 0; i < n; i++ {
+		out = append(out, s...)
+	}
+	L.Push(lua.LString(out))
+	return 1
+}
@@ -0,0 +1,209 @@
+package script
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.uber.org/zap"
+)
+
+type nopHost struct{}
+
+func (nopHost) Emit(string, interface{}, string)     {}
+func (nopHost) SetObject(string, int32, interface{}) {}
+func (nopHost) AddScore(string, int, string)         {}
+
+func TestRuntimeLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr error  // errors.Is 匹配
+		wantMsg string // 错误信息包含
+		timeout time.Duration
+	}{
+		{
+			name:   "normal script",
+			source: `local t = {} for i = 1, 1000 do t[i] = tostring(i) .. "x" end`,
+		},
+		{
+			name: "concat semantics kept",
+			source: `assert(1 .. 2 == "12")
+				assert("a" .. "b" .. "c" == "abc")
+				local t = setmetatable({}, {__concat = function(a, b) return "meta" end})
+				assert(t .. "x" == "meta" and "x" .. t == "meta")
+				assert(not pcall(function() return {} .. "x" end))`,
+		},
+		{
+			name:    "string doubling",
+			source:  `local s = "x" while true do s = s .. s end`,
+			wantMsg: "string concatenation result too large",
+		},
+		{
+			name: "concat inside nested function",
+			source: `local t = { f = function(s) return s .. s end }
+				local s = "x" for i = 1, 20 do s = t.f(s) end`,
+			wantMsg: "string concatenation result too large",
+		},
+		{
+			name:    "string.rep",
+			source:  `local s = string.rep("x", 1000000)`,
+			wantMsg: "string.rep result too large",
+		},
+		{
+			name:    "string.rep overflow",
+			source:  `local s = string.rep("xx", 2^62)`,
+			wantMsg: "string.rep result too large",
+		},
+		{
+			name:   "string helpers within limits",
+			source: `assert(string.format("%5.2f|%s", 1.25, "a") == " 1.25|a")
+				assert(string.gsub("hello world", "o", "0") == "hell0 w0rld")
+				assert((string.gsub("$a $b", "%$(%w+)", {a = "1", b = "2"})) == "1 2")
+				assert((string.gsub("abc", "%w", function(c) return c:upper() end)) == "ABC")
+				assert(select(2, string.gsub("aaa", "a", "b", 2)) == 2)
+				assert(table.concat({1, 2, 3}, ",") == "1,2,3")
+				assert(table.concat({1, 2, 3}, ",", 2) == "2,3")`,
+		},
+		{
+			name:    "string.format width",
+			source:  `local s = string.format(string.rep("%99d", 1000), 1)`,
+			wantMsg: "string result too large",
+		},
+		{
+			name:    "string.gsub too many matches",
+			source:  `local s = string.gsub(string.rep("x", 60000), "x", "yy")`,
+			wantMsg: "string.gsub too many replacements",
+		},
+		{
+			name:    "string.gsub string replacement",
+			source:  `local s = string.gsub(string.rep("x", 1000), "x", string.rep("y", 100))`,
+			wantMsg: "string.gsub result too large",
+		},
+		{
+			name:    "string.gsub function replacement",
+			source:  `local s = string.gsub(string.rep("x", 1000), "x", function() return string.rep("y", 100) end)`,
+			wantMsg: "string.gsub result too large",
+		},
+		{
+			name:    "table.concat",
+			source:  `local t = {} for i = 1, 100 do t[i] = string.rep("x", 1000) end local s = table.concat(t)`,
+			wantMsg: "string result too large",
+		},
+		{
+			name:    "table growth in one call",
+			source:  `local t = {} local i = 0 while true do i = i + 1 t[i] = {i} end`,
+			wantMsg: "timed out",
+			timeout: 50 * time.Millisecond,
+		},
+		{
+			name:    "retained globals",
+			source:  `big = {} for i = 1, 20000 do big[i] = string.rep("x", 1000) end`,
+			wantErr: errMemoryLimit,
+		},
+		{
+			name:    "busy loop",
+			source:  `while true do end`,
+			wantMsg: "timed out",
+			timeout: 50 * time.Millisecond,
+		},
+		{
+			name:    "file access removed",
+			source:  `dofile("/etc/passwd")`,
+			wantMsg: "attempt to call a non-function object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 2 * time.Second
+			}
+			r, err := NewRuntime("course", tt.source, nopHost{}, timeout, zap.NewNop())
+			if r != nil {
+				defer r.Close()
+			}
+			switch {
+			case tt.wantErr == nil && tt.wantMsg == "":
+				if err != nil {
+					t.Fatalf("NewRuntime() error = %v", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NewRuntime() error = %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("NewRuntime() error = %v, want containing %q", err, tt.wantMsg)
+				}
+			}
+		})
+	}
+}
+
+func TestInvokeResetsStateOverMemoryLimit(t *testing.T) {
+	// 每次回调向全局表追加约 2MB，第 8 次回调后超过保留上限
+	source := `grow = {} calls = 0
+		function on_message(device, name, data)
+			calls = calls + 1
+			for i = 1, 2000 do grow[#grow + 1] = string.rep("x", 1000) end
+		end`
+	r, err := NewRuntime("course", source, nopHost{}, 2*time.Second, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer r.Close()
+
+	for i := 0; i < 7; i++ {
+		r.Invoke(HookMessage, "S1", "grow", nil)
+	}
+	if r.failures != 0 || r.state.GetGlobal("calls") != lua.LNumber(7) {
+		t.Fatalf("state reset too early: failures=%d calls=%v", r.failures, r.state.GetGlobal("calls"))
+	}
+
+	r.Invoke(HookMessage, "S1", "grow", nil)
+	if r.disabled || r.state == nil || r.failures != 1 {
+		t.Fatalf("script not reloaded: disabled=%v failures=%d", r.disabled, r.failures)
+	}
+	if calls := r.state.GetGlobal("calls"); calls != lua.LNumber(0) {
+		t.Fatalf("reloaded state calls = %v, want 0", calls)
+	}
+
+	r.Invoke(HookMessage, "S1", "grow", nil)
+	if r.failures != 0 || r.state.GetGlobal("calls") != lua.LNumber(1) {
+		t.Fatalf("reloaded script not running: failures=%d calls=%v", r.failures, r.state.GetGlobal("calls"))
+	}
+}
+
+func TestConvertLimits(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	long := strings.Repeat("x", maxStringLength+1)
+	if v := toLua(L, long); v != lua.LNil {
+		t.Errorf("toLua(long string) = %v, want nil", v.Type())
+	}
+
+	items := make([]interface{}, 10000)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	table, ok := toLua(L, map[string]interface{}{"items": items}).(*lua.LTable)
+	if !ok {
+		t.Fatalf("toLua(map) is not a table")
+	}
+	if n := table.RawGetString("items").(*lua.LTable).Len(); n >= maxConvertItems {
+		t.Errorf("converted %d items, want fewer than %d", n, maxConvertItems)
+	}
+
+	back, ok := fromLua(table).(map[string]interface{})
+	if !ok {
+		t.Fatalf("fromLua(table) is not an object")
+	}
+	if n := len(back["items"].([]interface{})); n >= maxConvertItems {
+		t.Errorf("converted back %d items, want fewer than %d", n, maxConvertItems)
+	}
+}
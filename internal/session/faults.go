@@ -0,0 +1,88 @@
+package session
+
+import (
+	"time"
+
+	"xnfz/pkg/models"
+)
+
+// FaultRecord 会话中一次故障注入及学生的处置情况
+type FaultRecord struct {
+	FaultID      string                 `json:"faultId"`
+	InjectedBy   string                 `json:"injectedBy"`
+	Params       map[string]interface{} `json:"params,omitempty"`
+	ObjectIDs    []int32                `json:"objectIds,omitempty"`
+	InjectedAt   time.Time              `json:"injectedAt"`
+	RespondedAt  *time.Time             `json:"respondedAt,omitempty"`
+	ResponseTime float64                `json:"responseTime,omitempty"` // 从注入到开始处置的用时（秒）
+	ClearedAt    *time.Time             `json:"clearedAt,omitempty"`
+}
+
+// active 判断故障是否仍在等待处置且未被清除
+func (r *FaultRecord) active() bool {
+	return r.RespondedAt == nil && r.ClearedAt == nil
+}
+
+// InjectFault 记录一次故障注入，同一故障重复注入时产生新的记录
+func (s *Session) InjectFault(fault models.Fault, params map[string]interface{}, by string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Faults = append(s.Faults, FaultRecord{
+		FaultID:    fault.ID,
+		InjectedBy: by,
+		Params:     params,
+		ObjectIDs:  fault.ObjectIDs,
+		InjectedAt: at,
+	})
+}
+
+// RespondFaults 学生操作对象后，将作用于这些对象（或未指定对象）的待处置故障标记为已响应
+// 返回本次被响应的故障记录
+func (s *Session) RespondFaults(objectIDs []int32, at time.Time) []FaultRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var responded []FaultRecord
+	for i := range s.Faults {
+		record := &s.Faults[i]
+		if !record.active() || !targets(record.ObjectIDs, objectIDs) {
+			continue
+		}
+		respondedAt := at
+		record.RespondedAt = &respondedAt
+		record.ResponseTime = at.Sub(record.InjectedAt).Seconds()
+		responded = append(responded, *record)
+	}
+	return responded
+}
+
+// ClearFault 将故障的所有未结束记录标记为已清除，faultID 为空时清除全部故障
+func (s *Session) ClearFault(faultID string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.Faults {
+		record := &s.Faults[i]
+		if record.ClearedAt != nil || (faultID != "" && record.FaultID != faultID) {
+			continue
+		}
+		clearedAt := at
+		record.ClearedAt = &clearedAt
+	}
+}
+
+// targets 判断操作的对象是否命中故障对象，故障未指定对象时任何操作都算命中
+func targets(faultObjects []int32, objectIDs []int32) bool {
+	if len(faultObjects) == 0 {
+		return len(objectIDs) > 0
+	}
+	for _, target := range faultObjects {
+		for _, objectID := range objectIDs {
+			if target == objectID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package session
+
+import "time"
+
+// HelpRecord 学生的一次举手求助
+type HelpRecord struct {
+	Note           string     `json:"note,omitempty"`
+	RaisedAt       time.Time  `json:"raisedAt"`
+	AcknowledgedBy string     `json:"acknowledgedBy,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	ClearedBy      string     `json:"clearedBy,omitempty"`
+	ClearedAt      *time.Time `json:"clearedAt,omitempty"`
+}
+
+// RaiseHand 记录一次举手求助
+func (s *Session) RaiseHand(note string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Help = append(s.Help, HelpRecord{Note: note, RaisedAt: at})
+}
+
+// AcknowledgeHelp 记录教师响应最近一次未结束的求助
+func (s *Session) AcknowledgeHelp(by string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record := s.pendingHelp(); record != nil && record.AcknowledgedAt == nil {
+		record.AcknowledgedBy = by
+		record.AcknowledgedAt = &at
+	}
+}
+
+// ClearHelp 结束最近一次未结束的求助，by 为学生本人时表示放下手
+func (s *Session) ClearHelp(by string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record := s.pendingHelp(); record != nil {
+		record.ClearedBy = by
+		record.ClearedAt = &at
+	}
+}
+
+// pendingHelp 返回最近一次未结束的求助，调用方需持有锁
+func (s *Session) pendingHelp() *HelpRecord {
+	if n := len(s.Help); n > 0 && s.Help[n-1].ClearedAt == nil {
+		return &s.Help[n-1]
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package session
+
+import (
+	"time"
+
+	"xnfz/internal/scoring"
+)
+
+// maxPropertyChanges 会话记录中保留的属性变化条数上限
+const maxPropertyChanges = 1000
+
+// PropertyChange 会话中一次对象属性变化，Value 为空表示属性被删除
+type PropertyChange struct {
+	ObjectID int32       `json:"objectId"`
+	Name     string      `json:"name"`
+	Value    interface{} `json:"value"`
+	Time     time.Time   `json:"time"`
+}
+
+// RecordProperty 记录属性变化并交给评分引擎，超过上限的变化只参与评分不再记录
+// 返回 true 表示记录在这次变化时达到上限，调用方据此记录日志
+func (s *Session) RecordProperty(change PropertyChange) bool {
+	s.Track(scoring.Event{
+		Kind:     scoring.EventProperty,
+		ObjectID: change.ObjectID,
+		Property: change.Name,
+		Value:    change.Value,
+		Time:     change.Time,
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Properties) < maxPropertyChanges {
+		s.Properties = append(s.Properties, change)
+		return false
+	}
+	s.PropertiesDropped++
+	return s.PropertiesDropped == 1
+}
@@ -0,0 +1,26 @@
+package session
+
+import "time"
+
+// QuizAnswer 学生对一道测验题的作答
+type QuizAnswer struct {
+	QuizID     string    `json:"quizId"`
+	Question   string    `json:"question"`
+	Choices    []int     `json:"choices"`           // 选择的选项下标
+	Correct    *bool     `json:"correct,omitempty"` // 题目没有标准答案（投票）时为空
+	AnsweredAt time.Time `json:"answeredAt"`
+}
+
+// AnswerQuiz 记录作答，同一题目重复作答时覆盖之前的答案
+func (s *Session) AnswerQuiz(answer QuizAnswer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.Answers {
+		if s.Answers[i].QuizID == answer.QuizID {
+			s.Answers[i] = answer
+			return
+		}
+	}
+	s.Answers = append(s.Answers, answer)
+}
@@ -0,0 +1,209 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"xnfz/internal/scoring"
+
+	"go.uber.org/zap"
+)
+
+// SavedProgress 练习中断（下课、掉线）时保存的学生进度，下次选择同一课程时可以恢复
+type SavedProgress struct {
+	UserID    string          `json:"userId"`
+	CourseID  string          `json:"courseId"`
+	Steps     StepProgress    `json:"steps"`
+	Elapsed   float64         `json:"elapsed"`             // 已用时间（秒）
+	Workspace json.RawMessage `json:"workspace,omitempty"` // 个人工作区的对象状态
+	SavedAt   time.Time       `json:"savedAt"`
+
+	// 步骤开始、完成或跳过的时间（相对练习开始的秒数），恢复时按原时间重放评分事件
+	StepStarted  map[string]float64 `json:"stepStarted,omitempty"`
+	StepFinished map[string]float64 `json:"stepFinished,omitempty"`
+}
+
+// progressKey 保存进度的索引
+func progressKey(userID string, courseID string) string {
+	return courseID + "/" + userID
+}
+
+// Elapsed 返回会话已用时间，恢复的会话包含之前的用时
+func (s *Session) Elapsed() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Since(s.StartTime)
+}
+
+// Finished 判断会话是否已经完成课程的全部步骤
+func (s *Session) Finished() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.Course.Steps) > 0 && s.Steps.Current == "" && len(s.Steps.Completed)+len(s.Steps.Skipped) >= len(s.Course.Steps)
+}
+
+// stepOffsets 将步骤时间换算为相对会话开始的秒数，调用方需持有读锁
+func (s *Session) stepOffsets(times map[string]time.Time) map[string]float64 {
+	if len(times) == 0 {
+		return nil
+	}
+	offsets := make(map[string]float64, len(times))
+	for stepID, t := range times {
+		offsets[stepID] = t.Sub(s.StartTime).Seconds()
+	}
+	return offsets
+}
+
+// stepTimes 将保存的相对时间换算为恢复后会话中的时间，调用方需持有写锁
+func (s *Session) stepTimes(offsets map[string]float64) map[string]time.Time {
+	times := make(map[string]time.Time, len(offsets))
+	for stepID, offset := range offsets {
+		times[stepID] = s.StartTime.Add(time.Duration(offset * float64(time.Second)))
+	}
+	return times
+}
+
+// Resume 从保存的进度继续会话：恢复步骤进度，并将开始时间提前已用的时间
+// 评分引擎按新的开始时间重建，步骤事件按保存的时间顺序重放，顺序和限时规则与中断前一致
+// 旧版本保存的进度没有步骤时间，这些事件按当前时间计入
+func (s *Session) Resume(saved SavedProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.StartTime = now.Add(-time.Duration(saved.Elapsed * float64(time.Second)))
+	s.Steps = StepProgress{
+		Current:   saved.Steps.Current,
+		Completed: append([]string{}, saved.Steps.Completed...),
+		Skipped:   append([]string{}, saved.Steps.Skipped...),
+	}
+	s.stepStarted = s.stepTimes(saved.StepStarted)
+	s.stepFinished = s.stepTimes(saved.StepFinished)
+	if s.scorer.Load() == nil {
+		return
+	}
+
+	at := func(times map[string]time.Time, stepID string) time.Time {
+		if t, ok := times[stepID]; ok {
+			return t
+		}
+		return now
+	}
+	var events []scoring.Event
+	for stepID := range s.stepStarted {
+		events = append(events, scoring.Event{Kind: scoring.EventStepStart, StepID: stepID, Time: s.stepStarted[stepID]})
+	}
+	if _, ok := s.stepStarted[s.Steps.Current]; !ok && s.Steps.Current != "" {
+		events = append(events, scoring.Event{Kind: scoring.EventStepStart, StepID: s.Steps.Current, Time: now})
+	}
+	for _, stepID := range s.Steps.Completed {
+		events = append(events, scoring.Event{Kind: scoring.EventStepComplete, StepID: stepID, Time: at(s.stepFinished, stepID)})
+	}
+	for _, stepID := range s.Steps.Skipped {
+		events = append(events, scoring.Event{Kind: scoring.EventStepSkip, StepID: stepID, Time: at(s.stepFinished, stepID)})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+
+	scorer := scoring.NewEngine(s.Course.Scoring, s.StartTime)
+	for _, ev := range events {
+		scorer.Record(ev)
+	}
+	s.scorer.Store(scorer)
+}
+
+// SetProgressFile 设置练习进度的保存文件并加载已有进度，为空时只保存在内存中
+func (m *Manager) SetProgressFile(file string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.progressFile = file
+	m.progress = make(map[string]SavedProgress)
+	if file == "" {
+		return nil
+	}
+
+	raw, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []SavedProgress
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return err
+	}
+	for _, p := range saved {
+		m.progress[progressKey(p.UserID, p.CourseID)] = p
+	}
+	return nil
+}
+
+// SaveProgress 保存会话进度，覆盖同一学生同一课程之前的进度
+func (m *Manager) SaveProgress(s *Session, workspace json.RawMessage) {
+	state := s.StepState()
+	saved := SavedProgress{
+		UserID:    s.User.ID,
+		CourseID:  s.Course.ID,
+		Steps:     state,
+		Elapsed:   s.Elapsed().Seconds(),
+		Workspace: workspace,
+		SavedAt:   time.Now(),
+	}
+	s.mu.RLock()
+	saved.StepStarted = s.stepOffsets(s.stepStarted)
+	saved.StepFinished = s.stepOffsets(s.stepFinished)
+	s.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.progress[progressKey(saved.UserID, saved.CourseID)] = saved
+	m.saveProgressFile()
+
+	m.logger.Info("Saved practice progress",
+		zap.String("userID", saved.UserID),
+		zap.String("courseID", saved.CourseID),
+		zap.String("step", state.Current))
+}
+
+// Progress 返回学生在课程中保存的进度
+func (m *Manager) Progress(userID string, courseID string) (SavedProgress, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	saved, ok := m.progress[progressKey(userID, courseID)]
+	return saved, ok
+}
+
+// ResetProgress 删除保存的进度，userID 或 courseID 为空时匹配全部，返回删除的条数
+func (m *Manager) ResetProgress(userID string, courseID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := 0
+	for key, saved := range m.progress {
+		if (userID == "" || saved.UserID == userID) && (courseID == "" || saved.CourseID == courseID) {
+			delete(m.progress, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		m.saveProgressFile()
+	}
+	return removed
+}
+
+// saveProgressFile 标记进度需要写入文件，由 Run 在后台写入，调用方需持有锁
+func (m *Manager) saveProgressFile() {
+	if m.progressFile == "" {
+		return
+	}
+	m.progressDirty = true
+	m.notify()
+}
@@ -1,9 +1,12 @@
 package session
 
 import (
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"xnfz/internal/scoring"
 	"xnfz/pkg/models"
 
 	"github.com/google/uuid"
@@ -11,26 +14,108 @@ import (
 )
 
 type Session struct {
-	ID        string
-	User      *models.User
-	Course    *models.Course
-	StartTime time.Time
-	EndTime   time.Time
+	ID         string
+	User       *models.User
+	Course     *models.Course
+	StartTime  time.Time
+	EndTime    time.Time
+	Steps      StepProgress
+	Faults     []FaultRecord
+	Answers    []QuizAnswer
+	Help       []HelpRecord
+	Properties []PropertyChange
+	Score      *scoring.Result
+	// PropertiesDropped 超过记录上限未写入记录的属性变化数
+	PropertiesDropped int
+	scorer            atomic.Pointer[scoring.Engine] // 恢复进度时整体替换，Track 不持有会话锁
+	stepStarted       map[string]time.Time           // 步骤首次开始的时间
+	stepFinished      map[string]time.Time           // 步骤完成或跳过的时间
+	mu                sync.RWMutex
+}
+
+// Record 会话结束后保存的记录
+type Record struct {
+	ID         string           `json:"id"`
+	UserID     string           `json:"userId"`
+	CourseID   string           `json:"courseId"`
+	StartTime  time.Time        `json:"startTime"`
+	EndTime    time.Time        `json:"endTime"`
+	Steps      StepProgress     `json:"steps"`
+	Faults     []FaultRecord    `json:"faults,omitempty"`
+	Answers    []QuizAnswer     `json:"answers,omitempty"`
+	Help       []HelpRecord     `json:"help,omitempty"`
+	Properties []PropertyChange `json:"properties,omitempty"`
+	Score      *scoring.Result  `json:"score,omitempty"`
+	// PropertiesTruncated 属性变化超过记录上限，Properties 只包含前 maxPropertyChanges 条
+	PropertiesTruncated bool `json:"propertiesTruncated,omitempty"`
+	PropertiesDropped   int  `json:"propertiesDropped,omitempty"`
+}
+
+// Track 将事件交给课程评分引擎，课程没有评分规则时忽略
+func (s *Session) Track(ev scoring.Event) {
+	if scorer := s.scorer.Load(); scorer != nil {
+		scorer.Record(ev)
+	}
+}
+
+// Record 返回会话记录
+func (s *Session) Record() Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Record{
+		ID:         s.ID,
+		UserID:     s.User.ID,
+		CourseID:   s.Course.ID,
+		StartTime:  s.StartTime,
+		EndTime:    s.EndTime,
+		Steps:      s.Steps,
+		Faults:     append([]FaultRecord{}, s.Faults...),
+		Answers:    append([]QuizAnswer{}, s.Answers...),
+		Help:       append([]HelpRecord{}, s.Help...),
+		Properties: append([]PropertyChange{}, s.Properties...),
+		Score:      s.Score,
+
+		PropertiesTruncated: s.PropertiesDropped > 0,
+		PropertiesDropped:   s.PropertiesDropped,
+	}
 }
 
 type Manager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-	logger   *zap.Logger
+	sessions      map[string]*Session
+	recordDir     string
+	progress      map[string]SavedProgress // 练习模式下保存的进度
+	progressFile  string
+	records       []Record      // 等待写入的会话记录
+	progressDirty bool          // 保存的进度有尚未写入文件的变化
+	wake          chan struct{} // 通知 Run 有待写入的内容
+	mu            sync.RWMutex
+	logger        *zap.Logger
 }
 
 func NewManager(logger *zap.Logger) *Manager {
 	return &Manager{
 		sessions: make(map[string]*Session),
+		progress: make(map[string]SavedProgress),
+		wake:     make(chan struct{}, 1),
 		logger:   logger,
 	}
 }
 
+// SetRecordDir 设置会话记录的保存目录，为空时不保存
+func (m *Manager) SetRecordDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recordDir = dir
+	return nil
+}
+
 func (m *Manager) CreateSession(user *models.User, course *models.Course) *Session {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -41,6 +126,9 @@ func (m *Manager) CreateSession(user *models.User, course *models.Course) *Sessi
 		Course:    course,
 		StartTime: time.Now(),
 	}
+	if course.Scoring != nil || course.Script != "" {
+		session.scorer.Store(scoring.NewEngine(course.Scoring, session.StartTime))
+	}
 
 	m.sessions[session.ID] = session
 	m.logger.Info("Created new session", zap.String("sessionID", session.ID), zap.String("userID", user.ID), zap.String("courseID", course.ID))
@@ -61,8 +149,24 @@ func (m *Manager) EndSession(sessionID string) {
 	defer m.mu.Unlock()
 
 	if session, ok := m.sessions[sessionID]; ok {
+		session.mu.Lock()
 		session.EndTime = time.Now()
+		if scorer := session.scorer.Load(); scorer != nil {
+			session.Score = scorer.Result(session.EndTime)
+		}
+		session.mu.Unlock()
+
 		m.logger.Info("Ended session", zap.String("sessionID", sessionID), zap.String("userID", session.User.ID), zap.String("courseID", session.Course.ID))
 		delete(m.sessions, sessionID)
+		m.saveRecord(session)
+	}
+}
+
+// saveRecord 将会话记录加入写入队列，由 Run 写入记录目录，调用方需持有锁
+func (m *Manager) saveRecord(session *Session) {
+	if m.recordDir == "" {
+		return
 	}
+	m.records = append(m.records, session.Record())
+	m.notify()
 }
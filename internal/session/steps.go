@@ -0,0 +1,115 @@
+package session
+
+import (
+	"time"
+
+	"xnfz/internal/scoring"
+)
+
+// StepProgress 参与者在课程步骤中的进度
+type StepProgress struct {
+	Current   string   `json:"current"`
+	Completed []string `json:"completed"`
+	Skipped   []string `json:"skipped"`
+}
+
+// StepState 安全地获取步骤进度的副本
+func (s *Session) StepState() StepProgress {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return StepProgress{
+		Current:   s.Steps.Current,
+		Completed: append([]string{}, s.Steps.Completed...),
+		Skipped:   append([]string{}, s.Steps.Skipped...),
+	}
+}
+
+// startedLocked 记录步骤开始并交给评分引擎，只保留首次开始的时间，调用方需持有写锁
+func (s *Session) startedLocked(stepID string, now time.Time) {
+	if s.stepStarted == nil {
+		s.stepStarted = make(map[string]time.Time)
+	}
+	if _, ok := s.stepStarted[stepID]; !ok {
+		s.stepStarted[stepID] = now
+	}
+	s.Track(scoring.Event{Kind: scoring.EventStepStart, StepID: stepID, Time: now})
+}
+
+// finishedLocked 记录步骤完成或跳过的时间并交给评分引擎，调用方需持有写锁
+func (s *Session) finishedLocked(kind scoring.EventKind, stepID string, now time.Time) {
+	if s.stepFinished == nil {
+		s.stepFinished = make(map[string]time.Time)
+	}
+	s.stepFinished[stepID] = now
+	s.Track(scoring.Event{Kind: kind, StepID: stepID, Time: now})
+}
+
+// StartStep 设置当前步骤
+func (s *Session) StartStep(stepID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Steps.Current = stepID
+	if stepID != "" {
+		s.startedLocked(stepID, time.Now())
+	}
+}
+
+// CompleteStep 将步骤标记为完成，并将当前步骤设置为 next
+// 步骤已完成时返回 false
+func (s *Session) CompleteStep(stepID string, next string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if contains(s.Steps.Completed, stepID) {
+		return false
+	}
+	now := time.Now()
+	s.Steps.Completed = append(s.Steps.Completed, stepID)
+	s.Steps.Skipped = remove(s.Steps.Skipped, stepID)
+	s.finishedLocked(scoring.EventStepComplete, stepID, now)
+	if s.Steps.Current == stepID && next != stepID {
+		s.Steps.Current = next
+		if next != "" {
+			s.startedLocked(next, now)
+		}
+	}
+	return true
+}
+
+// SkipStep 将步骤标记为跳过，并将当前步骤设置为 next
+func (s *Session) SkipStep(stepID string, next string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if !contains(s.Steps.Skipped, stepID) && !contains(s.Steps.Completed, stepID) {
+		s.Steps.Skipped = append(s.Steps.Skipped, stepID)
+		s.finishedLocked(scoring.EventStepSkip, stepID, now)
+	}
+	if s.Steps.Current == stepID && next != stepID {
+		s.Steps.Current = next
+		if next != "" {
+			s.startedLocked(next, now)
+		}
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, v string) []string {
+	result := list[:0]
+	for _, item := range list {
+		if item != v {
+			result = append(result, item)
+		}
+	}
+	return result
+}
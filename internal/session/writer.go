@@ -0,0 +1,68 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// notify 通知 Run 有待写入的内容，不会阻塞
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run 在后台写入会话记录和保存的进度，结束会话、保存进度不等待磁盘写入
+// 连续的进度变化合并为一次写入，文件总是反映最新的进度
+func (m *Manager) Run() {
+	for range m.wake {
+		m.flush()
+	}
+}
+
+// flush 写入队列中的会话记录，进度有变化时重写进度文件
+func (m *Manager) flush() {
+	m.mu.Lock()
+	records := m.records
+	m.records = nil
+	recordDir := m.recordDir
+	var progress []SavedProgress
+	if m.progressDirty {
+		progress = make([]SavedProgress, 0, len(m.progress))
+		for _, p := range m.progress {
+			progress = append(progress, p)
+		}
+		m.progressDirty = false
+	}
+	progressFile := m.progressFile
+	m.mu.Unlock()
+
+	for _, record := range records {
+		data, err := json.MarshalIndent(record, "", "  ")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(recordDir, record.ID+".json"), data, 0o644)
+		}
+		if err != nil {
+			m.logger.Error("Failed to save session record", zap.String("sessionID", record.ID), zap.Error(err))
+		}
+	}
+
+	if progress == nil {
+		return
+	}
+	sort.Slice(progress, func(i, j int) bool {
+		return progressKey(progress[i].UserID, progress[i].CourseID) < progressKey(progress[j].UserID, progress[j].CourseID)
+	})
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err == nil {
+		err = os.WriteFile(progressFile, data, 0o644)
+	}
+	if err != nil {
+		m.logger.Error("Failed to save practice progress", zap.String("file", progressFile), zap.Error(err))
+	}
+}
@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// spatialAnchor 教室内的空间锚点，所有头显以它为共同坐标原点对齐
+type spatialAnchor struct {
+	ID        string      `json:"id"`
+	Transform []float64   `json:"transform"`      // 位置 xyz + 旋转四元数 xyzw
+	Data      interface{} `json:"data,omitempty"` // 平台相关的锚点数据（如云锚点 ID）
+	UpdatedBy string      `json:"updatedBy"`
+	UpdatedAt int64       `json:"updatedAt"` // Unix 毫秒
+}
+
+// anchorState 锚点状态推送，也是锚点文件的格式
+type anchorState struct {
+	Version     int64           `json:"version"`
+	Anchors     []spatialAnchor `json:"anchors"`
+	Recalibrate bool            `json:"recalibrate,omitempty"` // 教师重新校准，客户端需要重新对齐
+}
+
+// anchorRegistry 教室的空间锚点，每次修改版本号加一
+type anchorRegistry struct {
+	anchors map[string]spatialAnchor
+	version int64
+	file    string // 持久化文件，为空时只保存在内存中
+	mu      sync.RWMutex
+}
+
+// newAnchorRegistry 创建锚点注册表，并从文件恢复上次的校准结果
+func newAnchorRegistry(file string) (*anchorRegistry, error) {
+	r := &anchorRegistry{
+		anchors: make(map[string]spatialAnchor),
+		file:    file,
+	}
+	if file == "" {
+		return r, nil
+	}
+
+	raw, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+
+	var state anchorState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return r, err
+	}
+	r.version = state.Version
+	for _, anchor := range state.Anchors {
+		r.anchors[anchor.ID] = anchor
+	}
+	return r, nil
+}
+
+// state 返回当前锚点状态
+func (r *anchorRegistry) state() anchorState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stateLocked()
+}
+
+// stateLocked 返回按 ID 排序的锚点状态，调用方需持有锁
+func (r *anchorRegistry) stateLocked() anchorState {
+	anchors := make([]spatialAnchor, 0, len(r.anchors))
+	for _, anchor := range r.anchors {
+		anchors = append(anchors, anchor)
+	}
+	sort.Slice(anchors, func(i, j int) bool {
+		return anchors[i].ID < anchors[j].ID
+	})
+	return anchorState{Version: r.version, Anchors: anchors}
+}
+
+// apply 更新、删除锚点并写入文件，返回新的状态
+func (r *anchorRegistry) apply(set []spatialAnchor, remove []string, replace bool) (anchorState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if replace {
+		r.anchors = make(map[string]spatialAnchor)
+	}
+	for _, id := range remove {
+		delete(r.anchors, id)
+	}
+	for _, anchor := range set {
+		r.anchors[anchor.ID] = anchor
+	}
+	r.version++
+
+	state := r.stateLocked()
+	if r.file == "" {
+		return state, nil
+	}
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = os.WriteFile(r.file, raw, 0o644)
+	}
+	return state, err
+}
+
+// anchorSetRequest 教师设置锚点请求
+type anchorSetRequest struct {
+	Anchors []spatialAnchor `json:"anchors"`
+	Remove  []string        `json:"remove"`
+	Replace bool            `json:"replace"` // 清除未列出的锚点
+}
+
+// handleAnchorSet 处理教师设置或重新校准锚点消息，新状态推送给所有客户端
+func (c *Client) handleAnchorSet(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req anchorSetRequest
+	if err := decodeData(data, &req); err != nil || (len(req.Anchors) == 0 && len(req.Remove) == 0 && !req.Replace) {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	now := utils.NowMillis()
+	for i := range req.Anchors {
+		anchor := &req.Anchors[i]
+		if anchor.ID == "" || len(anchor.Transform) != poseLength || !finite(anchor.Transform) {
+			c.sendErrorResponse(e.ErrInvalidData)
+			return
+		}
+		anchor.UpdatedBy = c.user.ID
+		anchor.UpdatedAt = now
+	}
+
+	state, err := c.hub.anchors.apply(req.Anchors, req.Remove, req.Replace)
+	if err != nil {
+		c.hub.logger.Error("Failed to save anchors", zap.Error(err))
+	}
+
+	c.hub.logger.Info("Spatial anchors updated",
+		zap.String("teacher", c.user.ID),
+		zap.Int64("version", state.Version),
+		zap.Int("anchors", len(state.Anchors)))
+
+	state.Recalibrate = true
+	response := protocol.Message{
+		Type:       protocol.AnchorState,
+		DeviceCode: c.user.ID,
+		Data:       state,
+	}
+	c.hub.sendTo(allClients, marshalMessage(response))
+}
+
+// handleAnchorRequest 处理客户端重新获取锚点消息
+func (c *Client) handleAnchorRequest() {
+	c.sendMessage(protocol.Message{
+		Type: protocol.AnchorState,
+		Data: c.hub.anchors.state(),
+	})
+}
@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"sort"
+
+	"xnfz/api"
+	"xnfz/internal/assets"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// AssetPolicy 客户端资源包版本与课程不一致时的处理策略
+type AssetPolicy string
+
+const (
+	AssetWarn   AssetPolicy = "warn"   // 提示客户端更新并通知教师，仍允许操作
+	AssetReject AssetPolicy = "reject" // 资源包校验通过前拒绝客户端的对象操作
+)
+
+// Valid 判断策略是否合法
+func (p AssetPolicy) Valid() bool {
+	return p == AssetWarn || p == AssetReject
+}
+
+// assetManifestMessage 课程资源清单推送
+type assetManifestMessage struct {
+	CourseID string               `json:"courseId"`
+	Bundles  []models.AssetBundle `json:"bundles"`
+	Policy   AssetPolicy          `json:"policy"`
+}
+
+// assetCheckRequest 客户端上报已安装的资源包版本
+type assetCheckRequest struct {
+	CourseID string            `json:"courseId"`
+	Bundles  map[string]string `json:"bundles"` // 资源包 ID -> 版本
+}
+
+// assetMismatch 缺失或版本不一致的资源包
+type assetMismatch struct {
+	models.AssetBundle
+	Have string `json:"have"` // 客户端的版本，为空表示缺失
+}
+
+// assetStatus 资源包校验结果，推送给客户端本人及教师
+type assetStatus struct {
+	DeviceCode string          `json:"deviceCode"`
+	CourseID   string          `json:"courseId"`
+	OK         bool            `json:"ok"`
+	Mismatched []assetMismatch `json:"mismatched,omitempty"`
+}
+
+// assetManifest 返回课程资源清单推送，课程没有资源包时返回 false
+func (h *Hub) assetManifest(courseID string) (protocol.Message, bool) {
+	course, ok := h.courses.GetCourse(courseID)
+	if !ok || len(course.Assets) == 0 {
+		return protocol.Message{}, false
+	}
+	return protocol.Message{
+		Type: protocol.AssetManifest,
+		Data: assetManifestMessage{
+			CourseID: course.ID,
+			Bundles:  assets.Manifest(course),
+			Policy:   h.config.AssetPolicy,
+		},
+	}, true
+}
+
+// publishAssetManifest 课程开始时向所有客户端推送资源清单
+func (h *Hub) publishAssetManifest(courseID string) {
+	if msg, ok := h.assetManifest(courseID); ok {
+		h.sendTo(allClients, marshalMessage(msg))
+	}
+}
+
+// assetsVerified 返回客户端资源包校验通过的课程
+func (c *Client) assetsVerified() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.assetCourse
+}
+
+// requireAssets 拒绝策略下校验客户端已通过当前课程的资源包检查
+func (c *Client) requireAssets() bool {
+	if c.hub.config.AssetPolicy != AssetReject {
+		return true
+	}
+	courseID, _ := c.hub.courseDetail.Course()
+	course, ok := c.hub.courses.GetCourse(courseID)
+	if !ok || len(course.Assets) == 0 || c.assetsVerified() == courseID {
+		return true
+	}
+	c.sendErrorResponse(e.ErrAssetMismatch)
+	return false
+}
+
+// handleAssetCheck 处理客户端上报资源包版本的消息，返回缺失或版本不一致的资源包
+func (c *Client) handleAssetCheck(data interface{}) {
+	var req assetCheckRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	if req.CourseID == "" {
+		req.CourseID, _ = c.hub.courseDetail.Course()
+	}
+	course, ok := c.hub.courses.GetCourse(req.CourseID)
+	if !ok {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+
+	status := assetStatus{DeviceCode: c.user.ID, CourseID: course.ID}
+	for _, bundle := range assets.Manifest(course) {
+		if have := req.Bundles[bundle.ID]; have != bundle.Version {
+			status.Mismatched = append(status.Mismatched, assetMismatch{AssetBundle: bundle, Have: have})
+		}
+	}
+	sort.Slice(status.Mismatched, func(i, j int) bool {
+		return status.Mismatched[i].ID < status.Mismatched[j].ID
+	})
+	status.OK = len(status.Mismatched) == 0
+
+	c.mu.Lock()
+	if status.OK {
+		c.assetCourse = course.ID
+	} else if c.assetCourse == course.ID {
+		c.assetCourse = ""
+	}
+	c.mu.Unlock()
+
+	if !status.OK {
+		c.hub.logger.Warn("Asset bundles out of date",
+			zap.String("deviceCode", c.user.ID),
+			zap.String("courseID", course.ID),
+			zap.Int("mismatched", len(status.Mismatched)))
+	}
+
+	c.hub.sendTo(func(client *Client) bool {
+		return client == c || client.isStaff()
+	}, marshalMessage(protocol.Message{
+		Type:       protocol.AssetStatus,
+		DeviceCode: c.user.ID,
+		Data:       status,
+	}))
+	if !status.OK && c.hub.config.AssetPolicy == AssetReject {
+		c.sendErrorResponse(e.ErrAssetMismatch)
+	}
+}
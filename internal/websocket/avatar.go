@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"xnfz/api"
+)
+
+// 姿态数据长度
+const (
+	poseLength     = 7  // 位置 xyz + 旋转四元数 xyzw
+	maxFingerCurls = 10 // 两只手各五根手指的弯曲度
+)
+
+// avatarPose 参与者的头部与双手姿态，字段名压缩以减少高频消息的体积
+type avatarPose struct {
+	DeviceCode string    `json:"d"`
+	Head       []float64 `json:"h"`
+	Left       []float64 `json:"l,omitempty"`
+	Right      []float64 `json:"r,omitempty"`
+	Fingers    []float64 `json:"f,omitempty"` // 0（伸直）到 1（弯曲）
+}
+
+// valid 校验姿态数据的长度和取值
+func (p avatarPose) valid() bool {
+	if len(p.Head) != poseLength || !finite(p.Head) {
+		return false
+	}
+	for _, hand := range [][]float64{p.Left, p.Right} {
+		if len(hand) != 0 && (len(hand) != poseLength || !finite(hand)) {
+			return false
+		}
+	}
+	if len(p.Fingers) > maxFingerCurls {
+		return false
+	}
+	for _, curl := range p.Fingers {
+		if !(curl >= 0 && curl <= 1) {
+			return false
+		}
+	}
+	return true
+}
+
+// finite 判断所有数值都不是 NaN 或 Inf
+func finite(values []float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// avatarFrame 一次聚合推送的姿态集合
+type avatarFrame struct {
+	Poses []avatarPose `json:"poses"`
+}
+
+// avatarPoses 参与者的最新姿态，只保留最新一帧，不进入课程快照
+type avatarPoses struct {
+	latest    map[string]avatarPose
+	dirty     map[string]bool
+	lastFlush time.Time
+	mu        sync.Mutex
+}
+
+func newAvatarPoses() *avatarPoses {
+	return &avatarPoses{
+		latest: make(map[string]avatarPose),
+		dirty:  make(map[string]bool),
+	}
+}
+
+// update 用新姿态覆盖旧姿态
+func (a *avatarPoses) update(pose avatarPose) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.latest[pose.DeviceCode] = pose
+	a.dirty[pose.DeviceCode] = true
+}
+
+// remove 删除离线参与者的姿态
+func (a *avatarPoses) remove(deviceCode string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.latest, deviceCode)
+	delete(a.dirty, deviceCode)
+}
+
+// take 距上次推送超过 interval 时取出变化的姿态
+func (a *avatarPoses) take(now time.Time, interval time.Duration) []avatarPose {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.dirty) == 0 || now.Sub(a.lastFlush) < interval {
+		return nil
+	}
+	a.lastFlush = now
+
+	poses := make([]avatarPose, 0, len(a.dirty))
+	for deviceCode := range a.dirty {
+		poses = append(poses, a.latest[deviceCode])
+	}
+	a.dirty = make(map[string]bool)
+	return poses
+}
+
+// allScenes 教师和观察者接收的姿态帧标识（包含所有场景）
+const allScenes = "*"
+
+// flushAvatars 按配置的频率推送变化的姿态
+// 学生只收到同一场景内参与者及教师、观察者的姿态，教师和观察者收到全部姿态
+func (h *Hub) flushAvatars() {
+	poses := h.avatars.take(time.Now(), h.config.AvatarInterval)
+	if len(poses) == 0 {
+		return
+	}
+
+	// 每个客户端接收的帧：学生为所在场景，教师和观察者为 allScenes
+	frameOf := make(map[string]string)
+	for _, client := range h.clientsWhere(allClients) {
+		if client.isStaff() {
+			frameOf[client.user.ID] = allScenes
+		} else {
+			frameOf[client.user.ID] = h.sceneOf(client)
+		}
+	}
+
+	frames := make(map[string][]avatarPose)
+	var staffPoses []avatarPose
+	for _, pose := range poses {
+		frame, online := frameOf[pose.DeviceCode]
+		if !online {
+			continue
+		}
+		frames[allScenes] = append(frames[allScenes], pose)
+		if frame == allScenes {
+			staffPoses = append(staffPoses, pose)
+		} else {
+			frames[frame] = append(frames[frame], pose)
+		}
+	}
+
+	sent := make(map[string]bool)
+	for _, frame := range frameOf {
+		if sent[frame] {
+			continue
+		}
+		sent[frame] = true
+
+		framePoses := frames[frame]
+		if frame != allScenes {
+			framePoses = append(append([]avatarPose{}, framePoses...), staffPoses...)
+		}
+		if len(framePoses) == 0 {
+			continue
+		}
+
+		message := marshalMessage(protocol.Message{
+			Type: protocol.AvatarFrame,
+			Data: avatarFrame{Poses: framePoses},
+		})
+		h.sendTo(func(client *Client) bool {
+			return frameOf[client.user.ID] == frame && client.supports(protocol.CapAvatars)
+		}, message)
+	}
+}
+
+// handleAvatarPose 处理参与者上报的姿态，只保留最新一帧等待下次推送
+// 姿态是有损的高频数据，格式错误的帧直接丢弃，不回复错误
+func (c *Client) handleAvatarPose(data interface{}) {
+	var pose avatarPose
+	if err := decodeData(data, &pose); err != nil || !pose.valid() {
+		return
+	}
+	pose.DeviceCode = c.user.ID
+	c.hub.avatars.update(pose)
+}
@@ -0,0 +1,197 @@
+package websocket
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+	"xnfz/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+const maxChatLength = 200 // 单条聊天消息的最大字符数
+
+// 聊天消息类型
+const (
+	chatAnnouncement = "announcement" // 教师全体公告
+	chatPrivate      = "private"      // 学生与教师之间的私信
+)
+
+// chatEntry 一条聊天消息
+type chatEntry struct {
+	Kind     string `json:"kind"`
+	From     string `json:"from"`
+	FromName string `json:"fromName"`
+	To       string `json:"to,omitempty"` // 私信接收者，学生发给教师时为空表示全体教师
+	Text     string `json:"text"`
+	Time     int64  `json:"time"` // Unix 毫秒
+}
+
+// visibleTo 判断客户端是否可以看到这条消息：公告对所有人可见，私信对收发双方及教师、观察者可见
+func (m chatEntry) visibleTo(c *Client) bool {
+	return m.Kind == chatAnnouncement || c.isStaff() || c.user.ID == m.From || c.user.ID == m.To
+}
+
+// chatLog 最近的聊天记录，用于向新加入的客户端回放
+type chatLog struct {
+	entries []chatEntry
+	limit   int
+	mu      sync.RWMutex
+}
+
+func newChatLog(limit int) *chatLog {
+	return &chatLog{limit: limit}
+}
+
+// add 追加一条消息，超过上限时丢弃最早的消息
+func (l *chatLog) add(entry chatEntry) {
+	if l.limit <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if over := len(l.entries) - l.limit; over > 0 {
+		l.entries = append([]chatEntry{}, l.entries[over:]...)
+	}
+}
+
+// visibleTo 返回客户端可见的聊天记录
+func (l *chatLog) visibleTo(c *Client) []chatEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var result []chatEntry
+	for _, entry := range l.entries {
+		if entry.visibleTo(c) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// chatRequest 公告或私信请求
+type chatRequest struct {
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// chatText 校验并过滤消息内容，不允许发送时向发送者返回错误
+func (c *Client) chatText(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || utf8.RuneCountInString(text) > maxChatLength {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return "", false
+	}
+
+	filtered, ok := c.hub.config.ChatFilter(c.user.ID, text)
+	if !ok {
+		c.hub.logger.Warn("Chat message rejected by filter", zap.String("deviceCode", c.user.ID))
+		c.sendErrorResponse(e.ErrChatRejected)
+		return "", false
+	}
+	return filtered, true
+}
+
+// publishChat 记录消息并发送给可见的客户端
+func (h *Hub) publishChat(msgType int32, entry chatEntry) {
+	h.chat.add(entry)
+
+	response := protocol.Message{
+		Type:       msgType,
+		DeviceCode: entry.From,
+		Data:       entry,
+	}
+	h.sendTo(entry.visibleTo, marshalMessage(response))
+}
+
+// handleChatAnnouncement 处理教师全体公告消息
+func (c *Client) handleChatAnnouncement(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req chatRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	text, ok := c.chatText(req.Text)
+	if !ok {
+		return
+	}
+
+	c.hub.logger.Info("Announcement", zap.String("teacher", c.user.ID), zap.String("text", text))
+	c.hub.publishChat(protocol.ChatAnnouncement, chatEntry{
+		Kind:     chatAnnouncement,
+		From:     c.user.ID,
+		FromName: c.user.Name,
+		Text:     text,
+		Time:     utils.NowMillis(),
+	})
+}
+
+// handleChatPrivate 处理私信消息：学生只能发给教师，教师可以回复指定学生
+func (c *Client) handleChatPrivate(data interface{}) {
+	var req chatRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	switch c.user.Role {
+	case models.Student:
+		if !c.hub.config.PrivateChat {
+			c.sendErrorResponse(e.ErrPermissionDenied)
+			return
+		}
+		if !c.requireUnmuted() {
+			return
+		}
+		if req.To != "" && !c.hub.isTeacher(req.To) {
+			c.sendErrorResponse(e.ErrClientNotFound)
+			return
+		}
+	case models.Teacher:
+		if req.To == "" {
+			c.sendErrorResponse(e.ErrInvalidData)
+			return
+		}
+		if len(c.hub.clientsByUser(req.To)) == 0 {
+			c.sendErrorResponse(e.ErrClientNotFound)
+			return
+		}
+	default:
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return
+	}
+
+	text, ok := c.chatText(req.Text)
+	if !ok {
+		return
+	}
+
+	c.hub.publishChat(protocol.ChatPrivate, chatEntry{
+		Kind:     chatPrivate,
+		From:     c.user.ID,
+		FromName: c.user.Name,
+		To:       req.To,
+		Text:     text,
+		Time:     utils.NowMillis(),
+	})
+}
+
+// isTeacher 判断设备是否为在线教师
+func (h *Hub) isTeacher(deviceCode string) bool {
+	for _, client := range h.clientsByUser(deviceCode) {
+		if client.user.Role == models.Teacher {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,380 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// kickRejoinBlock 被踢出的学生在这段时间内不能重新连接
+const kickRejoinBlock = 2 * time.Minute
+
+// studentSwitch 可以作用于全体或部分学生的开关（冻结、静音）
+// overrides 中的值优先于 all，用于在全体开启时单独放行某些学生
+type studentSwitch struct {
+	all       bool
+	overrides map[string]bool
+}
+
+func newStudentSwitch() studentSwitch {
+	return studentSwitch{overrides: make(map[string]bool)}
+}
+
+// set 设置开关，deviceCodes 为空时作用于全体学生
+func (s *studentSwitch) set(deviceCodes []string, on bool) {
+	if len(deviceCodes) == 0 {
+		s.all = on
+		s.overrides = make(map[string]bool)
+		return
+	}
+	for _, code := range deviceCodes {
+		s.overrides[code] = on
+	}
+}
+
+// enabled 判断开关对指定学生是否开启
+func (s *studentSwitch) enabled(deviceCode string) bool {
+	if on, ok := s.overrides[deviceCode]; ok {
+		return on
+	}
+	return s.all
+}
+
+// list 返回单独开启的学生列表
+func (s *studentSwitch) list() []string {
+	result := make([]string, 0, len(s.overrides))
+	for code, on := range s.overrides {
+		if on {
+			result = append(result, code)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// classroomControls 保存教师课堂控制状态
+type classroomControls struct {
+	frozen    studentSwitch
+	muted     studentSwitch
+	following bool
+	leader    string      // 跟随模式下被跟随的教师 deviceCode
+	view      interface{} // 教师最新的视角变换
+	viewDirty bool
+	talking   map[string]bool      // 正在按键讲话的教师 deviceCode
+	kicked    map[string]time.Time // 被踢出的学生 deviceCode -> 可以重新连接的时间
+	mu        sync.RWMutex
+}
+
+func newClassroomControls() *classroomControls {
+	return &classroomControls{
+		frozen:  newStudentSwitch(),
+		muted:   newStudentSwitch(),
+		talking: make(map[string]bool),
+		kicked:  make(map[string]time.Time),
+	}
+}
+
+// classroomState 课堂控制状态推送
+type classroomState struct {
+	AllFrozen bool     `json:"allFrozen"`
+	Frozen    []string `json:"frozen"`
+	AllMuted  bool     `json:"allMuted"`
+	Muted     []string `json:"muted"`
+	Following bool     `json:"following"`
+	Leader    string   `json:"leader"`
+	Talking   []string `json:"talking"`
+}
+
+// classroomSwitchRequest 冻结或静音请求，deviceCodes 为空表示全体学生
+type classroomSwitchRequest struct {
+	DeviceCodes []string `json:"deviceCodes"`
+	Enabled     bool     `json:"enabled"`
+}
+
+// classroomKickRequest 踢出学生请求
+type classroomKickRequest struct {
+	DeviceCode string `json:"deviceCode"`
+	Reason     string `json:"reason"`
+}
+
+// followMeRequest 开启或关闭跟随模式请求
+type followMeRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// isFrozen 判断客户端的交互是否被冻结，教师不受影响
+func (cc *classroomControls) isFrozen(c *Client) bool {
+	if c.user.Role == models.Teacher {
+		return false
+	}
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.frozen.enabled(c.user.ID)
+}
+
+// isMuted 判断客户端是否被静音，教师不受影响
+func (cc *classroomControls) isMuted(c *Client) bool {
+	if c.user.Role == models.Teacher {
+		return false
+	}
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.muted.enabled(c.user.ID)
+}
+
+// kick 记录被踢出的学生，在 kickRejoinBlock 内拒绝其重新连接
+func (cc *classroomControls) kick(deviceCode string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	now := time.Now()
+	for code, until := range cc.kicked {
+		if now.After(until) {
+			delete(cc.kicked, code)
+		}
+	}
+	cc.kicked[deviceCode] = now.Add(kickRejoinBlock)
+}
+
+// isKicked 判断设备是否仍处于踢出后的禁止重连期
+func (cc *classroomControls) isKicked(deviceCode string) bool {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	until, ok := cc.kicked[deviceCode]
+	return ok && time.Now().Before(until)
+}
+
+// state 返回当前课堂控制状态
+func (cc *classroomControls) state() classroomState {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return classroomState{
+		AllFrozen: cc.frozen.all,
+		Frozen:    cc.frozen.list(),
+		AllMuted:  cc.muted.all,
+		Muted:     cc.muted.list(),
+		Following: cc.following,
+		Leader:    cc.leader,
+		Talking:   cc.talkers(),
+	}
+}
+
+// takeView 取出尚未推送的教师视角变换
+func (cc *classroomControls) takeView() (string, interface{}, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if !cc.following || !cc.viewDirty {
+		return "", nil, false
+	}
+	cc.viewDirty = false
+	return cc.leader, cc.view, true
+}
+
+// releaseLeader 被跟随的教师离线时关闭跟随模式，返回状态是否变化
+func (cc *classroomControls) releaseLeader(deviceCode string) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if !cc.following || cc.leader != deviceCode {
+		return false
+	}
+	cc.following = false
+	cc.leader = ""
+	cc.view = nil
+	cc.viewDirty = false
+	return true
+}
+
+// broadcastClassroomState 向所有客户端推送课堂控制状态
+func (h *Hub) broadcastClassroomState() {
+	response := protocol.Message{
+		Type: protocol.ClassroomState,
+		Data: h.classroom.state(),
+	}
+	h.sendTo(allClients, marshalMessage(response))
+}
+
+// flushFollowView 在每个 tick 向学生推送教师视角
+func (h *Hub) flushFollowView() {
+	leader, view, ok := h.classroom.takeView()
+	if !ok {
+		return
+	}
+
+	response := protocol.Message{
+		Type:       protocol.FollowMeTransform,
+		DeviceCode: leader,
+		Data:       view,
+	}
+	h.sendTo(func(c *Client) bool {
+		return c.user.Role == models.Student
+	}, marshalMessage(response))
+}
+
+// requireTeacher 校验客户端是否为教师，不是则返回权限错误
+func (c *Client) requireTeacher() bool {
+	if c.user.Role != models.Teacher {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return false
+	}
+	return true
+}
+
+// requireStaff 校验客户端是否为教师或观察者，不是则返回权限错误
+func (c *Client) requireStaff() bool {
+	if !c.isStaff() {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return false
+	}
+	return true
+}
+
+// requireUnmuted 校验客户端未被静音，被静音的学生不能发起私信和语音通话
+func (c *Client) requireUnmuted() bool {
+	if c.hub.classroom.isMuted(c) {
+		c.sendErrorResponse(e.ErrMuted)
+		return false
+	}
+	return true
+}
+
+// checkKicked 拒绝仍处于禁止重连期的被踢出设备
+func (h *Hub) checkKicked(conn *websocket.Conn, user *models.User) bool {
+	if !h.classroom.isKicked(user.ID) {
+		return true
+	}
+	h.logger.Warn("Kicked device rejected", zap.String("deviceCode", user.ID))
+	h.rejectHandshake(conn, e.ErrKicked, "", protocol.CloseKicked)
+	return false
+}
+
+// handleClassroomFreeze 处理冻结/解冻学生交互消息
+func (c *Client) handleClassroomFreeze(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req classroomSwitchRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	c.hub.classroom.mu.Lock()
+	c.hub.classroom.frozen.set(req.DeviceCodes, req.Enabled)
+	c.hub.classroom.mu.Unlock()
+
+	c.hub.logger.Info("Classroom freeze changed",
+		zap.String("teacher", c.user.ID),
+		zap.Strings("deviceCodes", req.DeviceCodes),
+		zap.Bool("frozen", req.Enabled))
+	c.hub.broadcastClassroomState()
+}
+
+// handleClassroomMute 处理静音/取消静音学生消息
+func (c *Client) handleClassroomMute(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req classroomSwitchRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	c.hub.classroom.mu.Lock()
+	c.hub.classroom.muted.set(req.DeviceCodes, req.Enabled)
+	c.hub.classroom.mu.Unlock()
+
+	c.hub.logger.Info("Classroom mute changed",
+		zap.String("teacher", c.user.ID),
+		zap.Strings("deviceCodes", req.DeviceCodes),
+		zap.Bool("muted", req.Enabled))
+	c.hub.broadcastClassroomState()
+}
+
+// handleClassroomKick 处理踢出学生消息
+func (c *Client) handleClassroomKick(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req classroomKickRequest
+	if err := decodeData(data, &req); err != nil || req.DeviceCode == "" {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	targets := c.hub.clientsByUser(req.DeviceCode)
+	if len(targets) == 0 {
+		c.sendErrorResponse(e.ErrClientNotFound)
+		return
+	}
+	for _, target := range targets {
+		if target.user.Role != models.Student {
+			c.sendErrorResponse(e.ErrPermissionDenied)
+			return
+		}
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "kicked by teacher"
+	}
+	c.hub.classroom.kick(req.DeviceCode)
+	for _, target := range targets {
+		target.disconnect(protocol.CloseKicked, reason)
+	}
+
+	c.hub.logger.Info("Student kicked",
+		zap.String("teacher", c.user.ID),
+		zap.String("deviceCode", req.DeviceCode))
+}
+
+// handleFollowMe 处理开启/关闭跟随模式消息
+func (c *Client) handleFollowMe(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req followMeRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	cc := c.hub.classroom
+	cc.mu.Lock()
+	cc.following = req.Enabled
+	cc.leader = ""
+	cc.view = nil
+	cc.viewDirty = false
+	if req.Enabled {
+		cc.leader = c.user.ID
+	}
+	cc.mu.Unlock()
+
+	c.hub.broadcastClassroomState()
+}
+
+// handleFollowMeTransform 处理教师视角变换，仅保存最新值，由 tick 统一推送
+func (c *Client) handleFollowMeTransform(data interface{}) {
+	cc := c.hub.classroom
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if !cc.following || cc.leader != c.user.ID {
+		return
+	}
+	cc.view = data
+	cc.viewDirty = true
+}
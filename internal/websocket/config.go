@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"time"
+
+	"xnfz/api"
+	"xnfz/internal/chat"
+	"xnfz/internal/device"
+)
+
+// DuplicatePolicy 同一 deviceCode 重复连接时的处理策略
+type DuplicatePolicy string
+
+const (
+	DuplicateKickOld   DuplicatePolicy = "kick-old"    // 踢出旧连接，保留新连接
+	DuplicateRejectNew DuplicatePolicy = "reject-new"  // 拒绝新连接
+	DuplicateAllow     DuplicatePolicy = "allow-multi" // 允许同一设备多连接
+)
+
+// Valid 判断策略是否合法
+func (p DuplicatePolicy) Valid() bool {
+	switch p {
+	case DuplicateKickOld, DuplicateRejectNew, DuplicateAllow:
+		return true
+	}
+	return false
+}
+
+// Config Hub 的运行配置
+type Config struct {
+	RosterToAll     bool             // 是否向所有客户端推送花名册（默认仅教师和观察者）
+	DuplicatePolicy DuplicatePolicy  // 重复 deviceCode 处理策略
+	IdleThreshold   time.Duration    // 学生无操作多久后在进度看板中标记为空闲
+	ScriptTimeout   time.Duration    // 课程脚本单次回调的最长执行时间
+	PrivateChat     bool             // 是否允许学生给教师发私信
+	ChatHistory     int              // 保留并向新加入的客户端回放的聊天消息条数
+	ChatFilter      chat.Filter      // 聊天内容过滤钩子
+	AvatarInterval  time.Duration    // 虚拟形象姿态的最短推送间隔（不低于 Hub 的 tick 周期）
+	AnchorFile      string           // 空间锚点持久化文件，为空时不保存
+	UndoHistory     int              // 授课模式下最多可撤销的操作步数，0 表示不记录历史
+	AssetPolicy     AssetPolicy      // 客户端资源包版本不一致时的处理策略
+	RequireHello    bool             // 是否要求客户端连接后首先发送 hello 握手消息
+	HelloTimeout    time.Duration    // 等待 hello 消息的最长时间
+	Compatibility   Compatibility    // 接受的客户端协议和应用版本范围
+	Devices         *device.Registry // 设备登记表，为空时使用不持久化的登记表
+	Classroom       string           // 本服务器所在教室，绑定到其他教室的设备将被拒绝，为空时不校验
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		DuplicatePolicy: DuplicateKickOld,
+		IdleThreshold:   time.Minute,
+		ScriptTimeout:   50 * time.Millisecond,
+		PrivateChat:     true,
+		ChatHistory:     100,
+		ChatFilter:      chat.AllowAll,
+		AvatarInterval:  tickPeriod,
+		UndoHistory:     50,
+		AssetPolicy:     AssetWarn,
+		RequireHello:    true,
+		HelloTimeout:    10 * time.Second,
+		Compatibility:   Compatibility{MinProtocol: protocol.Version, MaxProtocol: protocol.Version},
+	}
+}
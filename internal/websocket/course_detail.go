@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// CourseDetail 存储课程详情
+type CourseDetail struct {
+	CourseID   string                             `json:"courseId"`
+	Mode       int32                              `json:"mode"`
+	Data       map[int32]interface{}              `json:"data"`
+	Objects    map[int32]*SceneObject             `json:"objects"`    // 运行时生成的对象及层级关系
+	Properties map[int32]map[string]PropertyValue `json:"properties"` // 对象自定义属性
+	mu         sync.RWMutex
+}
+
+// NewCourseDetail 创建一个空的课程详情
+func NewCourseDetail(courseID string, mode int32) *CourseDetail {
+	return &CourseDetail{
+		CourseID:   courseID,
+		Mode:       mode,
+		Data:       make(map[int32]interface{}),
+		Objects:    make(map[int32]*SceneObject),
+		Properties: make(map[int32]map[string]PropertyValue),
+	}
+}
+
+// MarshalJSON 在读锁保护下序列化课程详情
+func (cd *CourseDetail) MarshalJSON() ([]byte, error) {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+	return json.Marshal(struct {
+		CourseID   string                             `json:"courseId"`
+		Mode       int32                              `json:"mode"`
+		Data       map[int32]interface{}              `json:"data"`
+		Objects    map[int32]*SceneObject             `json:"objects"`
+		Properties map[int32]map[string]PropertyValue `json:"properties"`
+	}{cd.CourseID, cd.Mode, cd.Data, cd.Objects, cd.Properties})
+}
+
+// Course 安全地获取课程 ID 和模式
+func (cd *CourseDetail) Course() (string, int32) {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+	return cd.CourseID, cd.Mode
+}
+
+// SetCourse 安全地设置课程 ID 和模式
+func (cd *CourseDetail) SetCourse(courseID string, mode int32) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	cd.CourseID = courseID
+	cd.Mode = mode
+}
+
+// Reset 清空课程详情
+func (cd *CourseDetail) Reset() {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	cd.CourseID = ""
+	cd.Mode = 0
+	cd.Data = make(map[int32]interface{})
+	cd.Objects = make(map[int32]*SceneObject)
+	cd.Properties = make(map[int32]map[string]PropertyValue)
+}
+
+// Merge 将对象操作数据合并到课程详情中
+func (cd *CourseDetail) Merge(data map[int32]interface{}) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	for k, v := range data {
+		cd.Data[k] = v
+	}
+}
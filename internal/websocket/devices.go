@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// deviceTelemetry 设备上报的状态
+type deviceTelemetry struct {
+	Battery  *int `json:"battery"` // 电量百分比 0-100
+	Charging bool `json:"charging"`
+}
+
+// checkClassroom 校验设备绑定的教室，绑定到其他教室的设备被拒绝
+func (h *Hub) checkClassroom(conn *websocket.Conn, user *models.User) bool {
+	if h.config.Classroom == "" {
+		return true
+	}
+	d, ok := h.devices.Get(user.ID)
+	if !ok || d.Classroom == "" || d.Classroom == h.config.Classroom {
+		return true
+	}
+
+	h.logger.Warn("Device bound to another classroom rejected",
+		zap.String("deviceCode", user.ID),
+		zap.String("boundClassroom", d.Classroom),
+		zap.String("classroom", h.config.Classroom))
+	h.rejectHandshake(conn, e.ErrDeviceNotAllowed, d.Classroom, protocol.CloseWrongClassroom)
+	return false
+}
+
+// handleDeviceTelemetry 处理设备上报电量的消息，并更新花名册
+func (c *Client) handleDeviceTelemetry(data interface{}) {
+	var req deviceTelemetry
+	if err := decodeData(data, &req); err != nil || req.Battery == nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	if *req.Battery < 0 || *req.Battery > 100 {
+		c.sendErrorResponse(e.ErrValueOutOfRange)
+		return
+	}
+
+	c.hub.devices.Telemetry(c.user.ID, *req.Battery, req.Charging)
+	c.hub.publishPresence(protocol.PresenceUpdate, c.participant())
+}
@@ -0,0 +1,211 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/internal/script"
+	"xnfz/internal/session"
+
+	"go.uber.org/zap"
+)
+
+// faultRequest 注入或清除故障请求，deviceCodes 和 groupIds 都为空时作用于全体学生
+type faultRequest struct {
+	FaultID     string                 `json:"faultId"`
+	Params      map[string]interface{} `json:"params"`
+	DeviceCodes []string               `json:"deviceCodes"`
+	GroupIDs    []string               `json:"groupIds"`
+}
+
+// faultMessage 故障注入或清除推送
+type faultMessage struct {
+	FaultID   string                 `json:"faultId"`
+	Title     string                 `json:"title,omitempty"`
+	ObjectIDs []int32                `json:"objectIds,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Targets   []string               `json:"targets"`
+	TeacherID string                 `json:"teacherId"`
+	Time      int64                  `json:"time"` // 注入或清除时间（Unix 毫秒）
+}
+
+// faultResponseMessage 学生开始处置故障推送
+type faultResponseMessage struct {
+	DeviceCode   string  `json:"deviceCode"`
+	FaultID      string  `json:"faultId"`
+	ResponseTime float64 `json:"responseTime"` // 秒
+}
+
+// faultTargets 返回请求作用的在线学生，未指定设备和分组时返回全体学生
+func (h *Hub) faultTargets(req faultRequest) []*Client {
+	devices := make(map[string]bool, len(req.DeviceCodes))
+	for _, deviceCode := range req.DeviceCodes {
+		devices[deviceCode] = true
+	}
+	groups := make(map[string]bool, len(req.GroupIDs))
+	for _, groupID := range req.GroupIDs {
+		groups[groupID] = true
+	}
+
+	return h.clientsWhere(func(client *Client) bool {
+		if !isStudent(client) {
+			return false
+		}
+		if len(devices) == 0 && len(groups) == 0 {
+			return true
+		}
+		if devices[client.user.ID] {
+			return true
+		}
+		groupID, ok := h.groups.groupOf(client.user.ID)
+		return ok && groups[groupID]
+	})
+}
+
+// publishFault 向目标学生及教师推送故障消息
+func (h *Hub) publishFault(msgType int32, deviceCode string, msg faultMessage, targets []*Client) {
+	recipients := make(map[*Client]bool, len(targets))
+	for _, target := range targets {
+		recipients[target] = true
+		msg.Targets = append(msg.Targets, target.user.ID)
+	}
+	sort.Strings(msg.Targets)
+
+	response := protocol.Message{
+		Type:       msgType,
+		DeviceCode: deviceCode,
+		Data:       msg,
+	}
+	h.sendTo(func(client *Client) bool {
+		return recipients[client] || client.isStaff()
+	}, marshalMessage(response))
+}
+
+// handleFaultInject 处理教师注入故障消息，请求参数覆盖课程定义的默认参数
+func (c *Client) handleFaultInject(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req faultRequest
+	if err := decodeData(data, &req); err != nil || req.FaultID == "" {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	course, found := c.hub.courses.GetCourse(courseID)
+	if !found {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+	fault, found := course.FindFault(req.FaultID)
+	if !found {
+		c.sendErrorResponse(e.ErrFaultNotFound)
+		return
+	}
+
+	targets := c.hub.faultTargets(req)
+	if len(targets) == 0 {
+		c.sendErrorResponse(e.ErrClientNotFound)
+		return
+	}
+
+	params := make(map[string]interface{}, len(fault.Params)+len(req.Params))
+	for k, v := range fault.Params {
+		params[k] = v
+	}
+	for k, v := range req.Params {
+		params[k] = v
+	}
+
+	now := time.Now()
+	for _, target := range targets {
+		if s := target.ensureSession(); s != nil {
+			s.InjectFault(fault, params, c.user.ID, now)
+		}
+	}
+
+	msg := faultMessage{
+		FaultID:   fault.ID,
+		Title:     fault.Title,
+		ObjectIDs: fault.ObjectIDs,
+		Params:    params,
+		TeacherID: c.user.ID,
+		Time:      now.UnixMilli(),
+	}
+	c.hub.publishFault(protocol.FaultInject, c.user.ID, msg, targets)
+
+	c.hub.logger.Info("Fault injected",
+		zap.String("teacher", c.user.ID),
+		zap.String("courseID", courseID),
+		zap.String("faultID", fault.ID),
+		zap.Int("targets", len(targets)),
+		zap.Time("time", now))
+
+	for _, target := range targets {
+		c.hub.invokeScript(script.HookFault, target.user.ID, fault.ID, params)
+	}
+}
+
+// handleFaultClear 处理教师清除故障消息，faultId 为空时清除全部故障
+func (c *Client) handleFaultClear(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req faultRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	now := time.Now()
+	targets := c.hub.faultTargets(req)
+	for _, target := range targets {
+		if s := target.getSession(); s != nil {
+			s.ClearFault(req.FaultID, now)
+		}
+	}
+
+	msg := faultMessage{
+		FaultID:   req.FaultID,
+		TeacherID: c.user.ID,
+		Time:      now.UnixMilli(),
+	}
+	c.hub.publishFault(protocol.FaultClear, c.user.ID, msg, targets)
+
+	c.hub.logger.Info("Fault cleared",
+		zap.String("teacher", c.user.ID),
+		zap.String("faultID", req.FaultID),
+		zap.Int("targets", len(targets)),
+		zap.Time("time", now))
+}
+
+// respondFaults 学生操作故障对象时记录响应时间，并通知教师
+func (c *Client) respondFaults(s *session.Session, data map[int32]interface{}) {
+	objectIDs := make([]int32, 0, len(data))
+	for objectID := range data {
+		objectIDs = append(objectIDs, objectID)
+	}
+
+	for _, record := range s.RespondFaults(objectIDs, time.Now()) {
+		c.hub.logger.Info("Fault response",
+			zap.String("deviceCode", c.user.ID),
+			zap.String("faultID", record.FaultID),
+			zap.Float64("responseTime", record.ResponseTime))
+
+		response := protocol.Message{
+			Type:       protocol.FaultResponse,
+			DeviceCode: c.user.ID,
+			Data: faultResponseMessage{
+				DeviceCode:   c.user.ID,
+				FaultID:      record.FaultID,
+				ResponseTime: record.ResponseTime,
+			},
+		}
+		c.hub.sendTo((*Client).isStaff, marshalMessage(response))
+	}
+}
@@ -0,0 +1,283 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+const defaultGroupSize = 4 // 自动分组时每组的默认人数
+
+// groupScene 返回分组共享的场景标识
+func groupScene(groupID string) string {
+	return "group:" + groupID
+}
+
+// breakoutGroup 一个协作分组
+type breakoutGroup struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// groupRegistry 管理课堂内的分组及学生所属关系
+type groupRegistry struct {
+	groups  map[string]*breakoutGroup
+	members map[string]string // 学生 deviceCode -> 分组 ID
+	nextID  int
+	mu      sync.RWMutex
+}
+
+func newGroupRegistry() *groupRegistry {
+	return &groupRegistry{
+		groups:  make(map[string]*breakoutGroup),
+		members: make(map[string]string),
+	}
+}
+
+// groupOf 返回学生所在的分组
+func (r *groupRegistry) groupOf(userID string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.members[userID]
+	return id, ok
+}
+
+// exists 判断分组是否存在
+func (r *groupRegistry) exists(groupID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.groups[groupID]
+	return ok
+}
+
+// replace 用新的分组替换现有分组
+func (r *groupRegistry) replace(groups []groupSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.groups = make(map[string]*breakoutGroup)
+	r.members = make(map[string]string)
+	for _, spec := range groups {
+		r.nextID++
+		group := &breakoutGroup{
+			ID:   fmt.Sprintf("g%d", r.nextID),
+			Name: spec.Name,
+		}
+		if group.Name == "" {
+			group.Name = fmt.Sprintf("Group %d", len(r.groups)+1)
+		}
+		r.groups[group.ID] = group
+		for _, member := range spec.Members {
+			r.moveLocked(member, group.ID)
+		}
+	}
+}
+
+// move 将学生移动到指定分组，groupID 为空表示移出分组
+func (r *groupRegistry) move(userID string, groupID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.moveLocked(userID, groupID)
+}
+
+func (r *groupRegistry) moveLocked(userID string, groupID string) {
+	if old, ok := r.members[userID]; ok {
+		if group, ok := r.groups[old]; ok {
+			for i, member := range group.Members {
+				if member == userID {
+					group.Members = append(group.Members[:i], group.Members[i+1:]...)
+					break
+				}
+			}
+		}
+		delete(r.members, userID)
+	}
+
+	if group, ok := r.groups[groupID]; ok {
+		group.Members = append(group.Members, userID)
+		r.members[userID] = groupID
+	}
+}
+
+// clear 解散所有分组，返回原分组中的学生和原分组的场景
+func (r *groupRegistry) clear() (members []string, scenes []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members = make([]string, 0, len(r.members))
+	for member := range r.members {
+		members = append(members, member)
+	}
+	scenes = make([]string, 0, len(r.groups))
+	for id := range r.groups {
+		scenes = append(scenes, groupScene(id))
+	}
+	r.groups = make(map[string]*breakoutGroup)
+	r.members = make(map[string]string)
+	return members, scenes
+}
+
+// list 返回所有分组，按 ID 排序
+func (r *groupRegistry) list() []breakoutGroup {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]breakoutGroup, 0, len(r.groups))
+	for _, group := range r.groups {
+		members := make([]string, len(group.Members))
+		copy(members, group.Members)
+		result = append(result, breakoutGroup{ID: group.ID, Name: group.Name, Members: members})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// groupSpec 手动分组时单个分组的定义
+type groupSpec struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// groupCreateRequest 创建分组请求，auto 为 true 时按 size 自动分配在线学生
+type groupCreateRequest struct {
+	Groups []groupSpec `json:"groups"`
+	Auto   bool        `json:"auto"`
+	Size   int         `json:"size"`
+}
+
+// groupMoveRequest 调整学生分组请求，groupId 为空表示移出分组
+type groupMoveRequest struct {
+	DeviceCode string `json:"deviceCode"`
+	GroupID    string `json:"groupId"`
+}
+
+// autoGroups 将学生均匀分配到若干分组
+func autoGroups(students []string, size int) []groupSpec {
+	if size <= 0 {
+		size = defaultGroupSize
+	}
+	count := (len(students) + size - 1) / size
+	specs := make([]groupSpec, count)
+	for i, student := range students {
+		specs[i%count].Members = append(specs[i%count].Members, student)
+	}
+	return specs
+}
+
+// onlineStudents 返回在线学生的 deviceCode，按字母排序
+func (h *Hub) onlineStudents() []string {
+	var students []string
+	for _, p := range h.roster() {
+		if p.Role == models.Student {
+			students = append(students, p.UserID)
+		}
+	}
+	return students
+}
+
+// broadcastGroupState 向所有客户端推送分组状态
+func (h *Hub) broadcastGroupState() {
+	response := protocol.Message{
+		Type: protocol.GroupState,
+		Data: h.groups.list(),
+	}
+	h.sendTo(allClients, marshalMessage(response))
+}
+
+// resyncScenes 向指定用户推送其当前场景的课程详情
+func (h *Hub) resyncScenes(userIDs []string) {
+	if courseID, _ := h.courseDetail.Course(); courseID == "" {
+		return
+	}
+
+	for _, userID := range userIDs {
+		for _, client := range h.clientsByUser(userID) {
+			client.sendMessage(protocol.Message{
+				Type: protocol.CourseDetail,
+				Data: h.sceneDetail(h.sceneOf(client)),
+			})
+		}
+	}
+}
+
+// handleGroupCreate 处理创建分组消息
+func (c *Client) handleGroupCreate(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req groupCreateRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	specs := req.Groups
+	if req.Auto {
+		specs = autoGroups(c.hub.onlineStudents(), req.Size)
+	}
+
+	affected, scenes := c.hub.groups.clear()
+	c.hub.scenes.remove(scenes...)
+	c.hub.groups.replace(specs)
+	for _, spec := range specs {
+		affected = append(affected, spec.Members...)
+	}
+
+	c.hub.logger.Info("Groups created",
+		zap.String("teacher", c.user.ID),
+		zap.Int("groups", len(specs)),
+		zap.Bool("auto", req.Auto))
+	c.hub.broadcastGroupState()
+	c.hub.resyncScenes(affected)
+}
+
+// handleGroupMove 处理调整学生分组消息
+func (c *Client) handleGroupMove(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req groupMoveRequest
+	if err := decodeData(data, &req); err != nil || req.DeviceCode == "" {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	if req.GroupID != "" && !c.hub.groups.exists(req.GroupID) {
+		c.sendErrorResponse(e.ErrGroupNotFound)
+		return
+	}
+
+	c.hub.groups.move(req.DeviceCode, req.GroupID)
+
+	c.hub.logger.Info("Student moved between groups",
+		zap.String("teacher", c.user.ID),
+		zap.String("deviceCode", req.DeviceCode),
+		zap.String("groupID", req.GroupID))
+	c.hub.broadcastGroupState()
+	c.hub.resyncScenes([]string{req.DeviceCode})
+}
+
+// handleGroupMerge 处理解散分组消息
+func (c *Client) handleGroupMerge() {
+	if !c.requireTeacher() {
+		return
+	}
+
+	affected, scenes := c.hub.groups.clear()
+	c.hub.scenes.remove(scenes...)
+
+	c.hub.logger.Info("Groups merged", zap.String("teacher", c.user.ID))
+	c.hub.broadcastGroupState()
+	c.hub.resyncScenes(affected)
+}
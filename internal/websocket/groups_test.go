@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// studentCodes 返回 S1 到 Sn
+func studentCodes(n int) []string {
+	codes := make([]string, n)
+	for i := range codes {
+		codes[i] = fmt.Sprintf("S%d", i+1)
+	}
+	return codes
+}
+
+func TestAutoGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		students int
+		size     int
+		want     [][]string
+	}{
+		{
+			name:     "no students",
+			students: 0,
+			size:     4,
+			want:     [][]string{},
+		},
+		{
+			name:     "even split",
+			students: 8,
+			size:     4,
+			want:     [][]string{{"S1", "S3", "S5", "S7"}, {"S2", "S4", "S6", "S8"}},
+		},
+		{
+			name:     "uneven split balances sizes",
+			students: 10,
+			size:     4,
+			want:     [][]string{{"S1", "S4", "S7", "S10"}, {"S2", "S5", "S8"}, {"S3", "S6", "S9"}},
+		},
+		{
+			name:     "one over a full group",
+			students: 5,
+			size:     4,
+			want:     [][]string{{"S1", "S3", "S5"}, {"S2", "S4"}},
+		},
+		{
+			name:     "fewer students than size",
+			students: 3,
+			size:     4,
+			want:     [][]string{{"S1", "S2", "S3"}},
+		},
+		{
+			name:     "size one",
+			students: 3,
+			size:     1,
+			want:     [][]string{{"S1"}, {"S2"}, {"S3"}},
+		},
+		{
+			name:     "default size",
+			students: 6,
+			size:     0,
+			want:     [][]string{{"S1", "S3", "S5"}, {"S2", "S4", "S6"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs := autoGroups(studentCodes(tt.students), tt.size)
+			got := make([][]string, len(specs))
+			for i, spec := range specs {
+				got[i] = spec.Members
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("autoGroups(%d students, %d) = %v, want %v", tt.students, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearGroupsRemovesScenes(t *testing.T) {
+	groups := newGroupRegistry()
+	scenes := newSceneRegistry()
+	groups.replace([]groupSpec{{Members: []string{"S1", "S2"}}, {Members: []string{"S3"}}})
+	for _, group := range groups.list() {
+		scenes.detail(groupScene(group.ID), "c1", 2)
+	}
+	scenes.observe("T1", groupScene("g1"))
+	scenes.demonstrate(groupScene("g2"))
+
+	members, removed := groups.clear()
+	scenes.remove(removed...)
+
+	if len(members) != 3 {
+		t.Errorf("clear() members = %v, want 3 students", members)
+	}
+	if len(scenes.details) != 0 {
+		t.Errorf("scenes left after merge: %v", scenes.details)
+	}
+	if scenes.watching("T1", groupScene("g1")) || scenes.watching("S1", groupScene("g2")) {
+		t.Error("removed group scenes are still observed or demonstrated")
+	}
+}
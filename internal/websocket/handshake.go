@@ -0,0 +1,198 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+	"xnfz/pkg/utils"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// anyPlatform 最低版本配置中匹配其他平台的键
+const anyPlatform = "*"
+
+// Compatibility 服务器接受的客户端范围
+type Compatibility struct {
+	MinProtocol   int               // 最低协议版本，最高为 protocol.Version
+	MaxProtocol   int               // 最高协议版本，最高为 protocol.Version
+	MinAppVersion map[string]string // 平台 -> 最低应用版本，"*" 匹配其他平台
+}
+
+// ParseMinAppVersions 解析形如 "quest=1.2.0,pico=1.1.0,*=1.0.0" 的最低版本配置
+func ParseMinAppVersions(s string) (map[string]string, error) {
+	versions := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		platform, version, ok := strings.Cut(item, "=")
+		if !ok || platform == "" || version == "" {
+			return nil, fmt.Errorf("invalid minimum version %q", item)
+		}
+		versions[strings.ToLower(platform)] = version
+	}
+	return versions, nil
+}
+
+// clientHello 客户端建立连接后必须首先发送的握手消息
+type clientHello struct {
+	AppVersion      string   `json:"appVersion"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	Platform        string   `json:"platform"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// helloAck 握手成功响应
+type helloAck struct {
+	ProtocolVersion int      `json:"protocolVersion"` // 协商后的协议版本
+	Capabilities    []string `json:"capabilities"`    // 服务器启用的能力
+	Encoding        string   `json:"encoding"`        // 服务器发送消息使用的帧类型：text 或 binary
+}
+
+// legacyHello 未开启强制握手时，未发送 hello 的客户端按旧版本处理：文本帧、启用全部功能
+var legacyHello = clientHello{
+	Capabilities: []string{protocol.CapAvatars},
+}
+
+// supportedCapabilities 服务器支持的能力
+var supportedCapabilities = []string{protocol.CapBinary, protocol.CapDeflate, protocol.CapAvatars}
+
+// Valid 判断协议版本范围是否有效
+func (cc Compatibility) Valid() bool {
+	return cc.MinProtocol > 0 && cc.MinProtocol <= cc.MaxProtocol && cc.MaxProtocol <= protocol.Version
+}
+
+// check 校验客户端版本，不兼容时返回提示客户端更新的原因
+func (cc Compatibility) check(hello clientHello) string {
+	if hello.ProtocolVersion < cc.MinProtocol {
+		return fmt.Sprintf("protocol version %d is no longer supported, minimum is %d", hello.ProtocolVersion, cc.MinProtocol)
+	}
+
+	minimum, ok := cc.MinAppVersion[strings.ToLower(hello.Platform)]
+	if !ok {
+		minimum = cc.MinAppVersion[anyPlatform]
+	}
+	if minimum != "" && utils.CompareVersions(hello.AppVersion, minimum) < 0 {
+		return fmt.Sprintf("app version %s is too old for %s, please update to %s or later", hello.AppVersion, hello.Platform, minimum)
+	}
+	return ""
+}
+
+// negotiate 返回双方都支持的能力
+func (hello clientHello) negotiate() map[string]bool {
+	enabled := make(map[string]bool)
+	for _, capability := range hello.Capabilities {
+		for _, supported := range supportedCapabilities {
+			if capability == supported {
+				enabled[capability] = true
+			}
+		}
+	}
+	return enabled
+}
+
+// rejectHandshake 向未完成握手的连接发送错误并以指定原因码关闭
+func (h *Hub) rejectHandshake(conn *websocket.Conn, err e.ErrorMessage, detail string, code int) {
+	message := err.Message
+	if detail != "" {
+		message += ": " + detail
+	}
+
+	deadline := time.Now().Add(writeWait)
+	conn.SetWriteDeadline(deadline)
+	conn.WriteMessage(websocket.TextMessage, marshalMessage(protocol.Message{
+		Type: protocol.ErrorMessage,
+		Code: err.Code,
+		Data: message,
+	}))
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, err.Message), deadline)
+	conn.Close()
+}
+
+// handshake 读取客户端的 hello 消息并校验版本，失败时发送错误并关闭连接
+func (h *Hub) handshake(conn *websocket.Conn, user *models.User) (clientHello, bool) {
+	if !h.config.RequireHello {
+		return legacyHello, true
+	}
+
+	conn.SetReadDeadline(time.Now().Add(h.config.HelloTimeout))
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		h.logger.Warn("Handshake failed", zap.String("deviceCode", user.ID), zap.Error(err))
+		h.rejectHandshake(conn, e.ErrHelloRequired, "", protocol.CloseHelloRequired)
+		return clientHello{}, false
+	}
+
+	var msg protocol.Message
+	var hello clientHello
+	if json.Unmarshal(raw, &msg) != nil || msg.Type != protocol.Hello || decodeData(msg.Data, &hello) != nil ||
+		hello.ProtocolVersion <= 0 || hello.AppVersion == "" || hello.Platform == "" {
+		h.rejectHandshake(conn, e.ErrHelloRequired, "", protocol.CloseHelloRequired)
+		return clientHello{}, false
+	}
+
+	// 客户端比服务器新，更新客户端无法解决，单独使用关闭原因码
+	if maximum := h.config.Compatibility.MaxProtocol; hello.ProtocolVersion > maximum {
+		h.logger.Warn("Client protocol too new",
+			zap.String("deviceCode", user.ID),
+			zap.String("appVersion", hello.AppVersion),
+			zap.Int("protocolVersion", hello.ProtocolVersion),
+			zap.Int("maxProtocol", maximum))
+		h.rejectHandshake(conn, e.ErrProtocolTooNew,
+			fmt.Sprintf("protocol version %d is newer than the maximum supported %d", hello.ProtocolVersion, maximum),
+			protocol.CloseIncompatible)
+		return clientHello{}, false
+	}
+
+	if reason := h.config.Compatibility.check(hello); reason != "" {
+		h.logger.Warn("Incompatible client rejected",
+			zap.String("deviceCode", user.ID),
+			zap.String("appVersion", hello.AppVersion),
+			zap.Int("protocolVersion", hello.ProtocolVersion),
+			zap.String("platform", hello.Platform))
+		h.rejectHandshake(conn, e.ErrUpdateRequired, reason, protocol.CloseUpdateRequired)
+		return clientHello{}, false
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	return hello, true
+}
+
+// welcome 返回握手成功响应
+func (c *Client) welcome() protocol.Message {
+	ack := helloAck{
+		ProtocolVersion: min(c.hello.ProtocolVersion, c.hub.config.Compatibility.MaxProtocol),
+		Capabilities:    make([]string, 0, len(c.capabilities)),
+		Encoding:        "text",
+	}
+	for _, capability := range supportedCapabilities {
+		if c.capabilities[capability] {
+			ack.Capabilities = append(ack.Capabilities, capability)
+		}
+	}
+	if c.supports(protocol.CapBinary) {
+		ack.Encoding = "binary"
+	}
+	return protocol.Message{Type: protocol.HelloAck, DeviceCode: c.user.ID, Data: ack}
+}
+
+// supports 判断客户端是否在握手时声明并启用了指定能力
+func (c *Client) supports(capability string) bool {
+	return c.capabilities[capability]
+}
+
+// frameType 返回向客户端发送消息使用的帧类型
+func (c *Client) frameType() int {
+	if c.supports(protocol.CapBinary) {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
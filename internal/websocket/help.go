@@ -0,0 +1,247 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// helpRequest 求助队列中的一项
+type helpRequest struct {
+	DeviceCode     string `json:"deviceCode"`
+	Name           string `json:"name"`
+	Note           string `json:"note,omitempty"`
+	RaisedAt       int64  `json:"raisedAt"` // Unix 毫秒
+	AcknowledgedBy string `json:"acknowledgedBy,omitempty"`
+}
+
+// helpQueue 按举手先后排列的求助队列
+type helpQueue struct {
+	requests []helpRequest
+	mu       sync.Mutex
+}
+
+// raise 将学生加入队尾，已在队列中时只更新备注并返回 false
+func (q *helpQueue) raise(user *models.User, note string, at time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if i := q.indexOf(user.ID); i >= 0 {
+		q.requests[i].Note = note
+		return false
+	}
+	q.requests = append(q.requests, helpRequest{
+		DeviceCode: user.ID,
+		Name:       user.Name,
+		Note:       note,
+		RaisedAt:   at.UnixMilli(),
+	})
+	return true
+}
+
+// acknowledge 标记求助已被教师响应，学生不在队列中时返回 false
+func (q *helpQueue) acknowledge(deviceCode string, by string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.indexOf(deviceCode)
+	if i < 0 {
+		return false
+	}
+	q.requests[i].AcknowledgedBy = by
+	return true
+}
+
+// remove 将学生移出队列，deviceCode 为空时清空队列，返回被移出的学生
+func (q *helpQueue) remove(deviceCode string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var removed []string
+	kept := q.requests[:0]
+	for _, request := range q.requests {
+		if deviceCode == "" || request.DeviceCode == deviceCode {
+			removed = append(removed, request.DeviceCode)
+			continue
+		}
+		kept = append(kept, request)
+	}
+	q.requests = kept
+	return removed
+}
+
+// list 返回队列的副本
+func (q *helpQueue) list() []helpRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]helpRequest{}, q.requests...)
+}
+
+// indexOf 返回学生在队列中的位置，调用方需持有锁
+func (q *helpQueue) indexOf(deviceCode string) int {
+	for i, request := range q.requests {
+		if request.DeviceCode == deviceCode {
+			return i
+		}
+	}
+	return -1
+}
+
+// helpRaiseRequest 学生举手或放下手请求
+type helpRaiseRequest struct {
+	Raised bool   `json:"raised"`
+	Note   string `json:"note"`
+}
+
+// helpTargetRequest 教师响应或清除求助请求，清除时 deviceCode 为空表示清空队列
+type helpTargetRequest struct {
+	DeviceCode string `json:"deviceCode"`
+	Observe    bool   `json:"observe"` // 响应时是否同时观察学生所在场景
+}
+
+// helpStatus 推送给学生本人的求助状态
+type helpStatus struct {
+	Raised         bool   `json:"raised"`
+	Position       int    `json:"position,omitempty"` // 队列中的位置，从 1 开始
+	AcknowledgedBy string `json:"acknowledgedBy,omitempty"`
+}
+
+// publishHelpQueue 向教师和观察者推送完整队列，并向 affected 中的学生推送各自的状态
+func (h *Hub) publishHelpQueue(affected []string) {
+	queue := h.help.list()
+
+	response := protocol.Message{
+		Type: protocol.HelpQueue,
+		Data: queue,
+	}
+	h.sendTo((*Client).isStaff, marshalMessage(response))
+
+	statuses := make(map[string]helpStatus, len(affected)+len(queue))
+	for _, deviceCode := range affected {
+		statuses[deviceCode] = helpStatus{}
+	}
+	for i, request := range queue {
+		statuses[request.DeviceCode] = helpStatus{
+			Raised:         true,
+			Position:       i + 1,
+			AcknowledgedBy: request.AcknowledgedBy,
+		}
+	}
+	for deviceCode, status := range statuses {
+		statusMessage := protocol.Message{
+			Type:       protocol.HelpRaise,
+			DeviceCode: deviceCode,
+			Data:       status,
+		}
+		h.sendTo(func(client *Client) bool {
+			return client.user.ID == deviceCode
+		}, marshalMessage(statusMessage))
+	}
+}
+
+// handleHelpRaise 处理学生举手或放下手消息
+func (c *Client) handleHelpRaise(data interface{}) {
+	if c.user.Role != models.Student {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return
+	}
+
+	var req helpRaiseRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	now := time.Now()
+	if req.Raised {
+		if c.hub.help.raise(c.user, req.Note, now) {
+			if s := c.ensureSession(); s != nil {
+				s.RaiseHand(req.Note, now)
+			}
+			c.hub.logger.Info("Hand raised", zap.String("deviceCode", c.user.ID), zap.String("note", req.Note))
+		}
+	} else if len(c.hub.help.remove(c.user.ID)) > 0 {
+		if s := c.getSession(); s != nil {
+			s.ClearHelp(c.user.ID, now)
+		}
+		c.hub.logger.Info("Hand lowered", zap.String("deviceCode", c.user.ID))
+	}
+
+	c.hub.publishHelpQueue([]string{c.user.ID})
+}
+
+// handleHelpAcknowledge 处理教师响应求助消息，observe 为 true 时同时观察学生所在场景
+func (c *Client) handleHelpAcknowledge(data interface{}) {
+	if !c.requireStaff() {
+		return
+	}
+
+	var req helpTargetRequest
+	if err := decodeData(data, &req); err != nil || req.DeviceCode == "" {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	if !c.hub.help.acknowledge(req.DeviceCode, c.user.ID) {
+		c.sendErrorResponse(e.ErrClientNotFound)
+		return
+	}
+
+	now := time.Now()
+	students := c.hub.clientsByUser(req.DeviceCode)
+	for _, student := range students {
+		if s := student.getSession(); s != nil {
+			s.AcknowledgeHelp(c.user.ID, now)
+		}
+	}
+
+	c.hub.logger.Info("Help acknowledged",
+		zap.String("teacher", c.user.ID),
+		zap.String("deviceCode", req.DeviceCode),
+		zap.Bool("observe", req.Observe))
+
+	c.hub.publishHelpQueue([]string{req.DeviceCode})
+
+	if req.Observe && len(students) > 0 {
+		key := c.hub.sceneOf(students[0])
+		snapshot := workspaceSnapshot{DeviceCode: req.DeviceCode}
+		if groupID, ok := c.hub.groups.groupOf(req.DeviceCode); ok {
+			snapshot.GroupID = groupID
+		}
+		c.observe(key, snapshot)
+	}
+}
+
+// handleHelpClear 处理教师清除求助消息，deviceCode 为空时清空队列
+func (c *Client) handleHelpClear(data interface{}) {
+	if !c.requireStaff() {
+		return
+	}
+
+	var req helpTargetRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	now := time.Now()
+	removed := c.hub.help.remove(req.DeviceCode)
+	for _, deviceCode := range removed {
+		for _, student := range c.hub.clientsByUser(deviceCode) {
+			if s := student.getSession(); s != nil {
+				s.ClearHelp(c.user.ID, now)
+			}
+		}
+	}
+
+	c.hub.logger.Info("Help cleared",
+		zap.String("teacher", c.user.ID),
+		zap.String("deviceCode", req.DeviceCode),
+		zap.Int("count", len(removed)))
+
+	c.hub.publishHelpQueue(removed)
+}
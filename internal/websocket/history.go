@@ -0,0 +1,365 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+	"xnfz/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxCheckpoints        = 32                     // 每节课最多保存的命名检查点数
+	historyCoalesceWindow = 500 * time.Millisecond // 连续同类操作合并为一步的时间窗口
+)
+
+// sceneState 场景对象状态的副本
+// 操作数据按对象整体替换、不会原地修改，因此只需复制外层 map
+type sceneState struct {
+	data       map[int32]interface{}
+	objects    map[int32]SceneObject
+	properties map[int32]map[string]PropertyValue
+}
+
+// snapshot 复制当前的场景状态
+func (cd *CourseDetail) snapshot() sceneState {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+
+	state := sceneState{
+		data:       make(map[int32]interface{}, len(cd.Data)),
+		objects:    make(map[int32]SceneObject, len(cd.Objects)),
+		properties: make(map[int32]map[string]PropertyValue, len(cd.Properties)),
+	}
+	for id, value := range cd.Data {
+		state.data[id] = value
+	}
+	for id, object := range cd.Objects {
+		state.objects[id] = *object
+	}
+	for id, properties := range cd.Properties {
+		copied := make(map[string]PropertyValue, len(properties))
+		for name, value := range properties {
+			copied[name] = value
+		}
+		state.properties[id] = copied
+	}
+	return state
+}
+
+// restore 用保存的状态替换场景状态，课程和模式不变
+func (cd *CourseDetail) restore(state sceneState) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	cd.Data = make(map[int32]interface{}, len(state.data))
+	for id, value := range state.data {
+		cd.Data[id] = value
+	}
+	cd.Objects = make(map[int32]*SceneObject, len(state.objects))
+	for id, object := range state.objects {
+		copied := object
+		cd.Objects[id] = &copied
+	}
+	cd.Properties = make(map[int32]map[string]PropertyValue, len(state.properties))
+	for id, properties := range state.properties {
+		copied := make(map[string]PropertyValue, len(properties))
+		for name, value := range properties {
+			copied[name] = value
+		}
+		cd.Properties[id] = copied
+	}
+}
+
+// historyEntry 一次状态变化之后的场景状态
+type historyEntry struct {
+	Label string `json:"label"`
+	By    string `json:"by"`
+	Time  int64  `json:"time"` // Unix 毫秒
+	state sceneState
+	final bool // 不与之后的操作合并（恢复检查点）
+}
+
+// sceneHistory 授课模式下共享场景的操作历史和命名检查点
+// states[cursor] 为当前状态，cursor 之后的记录可以重做
+type sceneHistory struct {
+	states      []historyEntry
+	cursor      int
+	checkpoints map[string]historyEntry
+	limit       int // 最多可撤销的步数
+	mu          sync.Mutex
+}
+
+func newSceneHistory(limit int) *sceneHistory {
+	return &sceneHistory{
+		checkpoints: make(map[string]historyEntry),
+		limit:       limit,
+	}
+}
+
+// reset 清空历史和检查点，以 initial 作为新的起点
+func (sh *sceneHistory) reset(initial sceneState) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	sh.states = []historyEntry{{Label: "start", Time: utils.NowMillis(), state: initial}}
+	sh.cursor = 0
+	sh.checkpoints = make(map[string]historyEntry)
+}
+
+// push 记录一次状态变化，丢弃可重做的记录，返回是否新增了一步
+// 同一用户在合并窗口内的同类操作（如拖动）合并为一步
+// 状态在持有历史锁时读取，保证并发修改按发生顺序入栈
+func (sh *sceneHistory) push(label string, by string, capture func() sceneState) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if len(sh.states) == 0 {
+		return false
+	}
+	now := utils.NowMillis()
+	if sh.limit <= 0 {
+		// 未开启历史记录时，恢复检查点留下的撤销步骤只在下一次修改前有效，否则撤销会丢失未记录的修改
+		if len(sh.states) == 1 {
+			return false
+		}
+		sh.states = []historyEntry{{Label: label, By: by, Time: now, state: capture()}}
+		sh.cursor = 0
+		return true
+	}
+
+	if last := &sh.states[sh.cursor]; sh.cursor > 0 && sh.cursor == len(sh.states)-1 && !last.final &&
+		last.Label == label && last.By == by && now-last.Time < historyCoalesceWindow.Milliseconds() {
+		last.Time = now
+		last.state = capture()
+		return false
+	}
+
+	sh.appendLocked(historyEntry{
+		Label: label,
+		By:    by,
+		Time:  now,
+		state: capture(),
+	}, sh.limit)
+	return true
+}
+
+// appendLocked 在当前位置之后追加一步并丢弃可重做的记录，最多保留 limit 步可撤销，调用方需持有锁
+func (sh *sceneHistory) appendLocked(entry historyEntry, limit int) {
+	sh.states = append(sh.states[:sh.cursor+1], entry)
+	if overflow := len(sh.states) - limit - 1; overflow > 0 {
+		sh.states = append([]historyEntry(nil), sh.states[overflow:]...)
+	}
+	sh.cursor = len(sh.states) - 1
+}
+
+// restoreCheckpoint 恢复命名检查点：在持有历史锁时通过 apply 写回场景，并作为不与其他操作合并的独立一步入栈
+// 未开启历史记录（limit <= 0）时也保留这一步，以恢复前的场景作为撤销目标，保证恢复检查点总能撤销
+func (sh *sceneHistory) restoreCheckpoint(name string, by string, capture func() sceneState, apply func(sceneState)) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	checkpoint, ok := sh.checkpoints[name]
+	if !ok || len(sh.states) == 0 {
+		return false
+	}
+
+	now := utils.NowMillis()
+	limit := sh.limit
+	if limit <= 0 {
+		// 未记录历史时当前记录不是最新的场景状态
+		sh.states = []historyEntry{{Label: "before restore", By: by, Time: now, state: capture()}}
+		sh.cursor = 0
+		limit = 1
+	}
+	apply(checkpoint.state)
+	sh.appendLocked(historyEntry{
+		Label: "restore:" + name,
+		By:    by,
+		Time:  now,
+		state: checkpoint.state,
+		final: true,
+	}, limit)
+	return true
+}
+
+// move 撤销（steps 为负）或重做若干步，返回实际移动的步数
+// 移动后的状态在持有历史锁时通过 apply 写回场景，避免并发的修改在写回前入栈后被覆盖
+func (sh *sceneHistory) move(steps int, apply func(sceneState)) int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if len(sh.states) == 0 {
+		return 0
+	}
+	target := sh.cursor + steps
+	if target < 0 {
+		target = 0
+	}
+	if target > len(sh.states)-1 {
+		target = len(sh.states) - 1
+	}
+	moved := target - sh.cursor
+	if moved != 0 {
+		sh.cursor = target
+		apply(sh.states[target].state)
+	}
+	return moved
+}
+
+// save 将当前状态保存为命名检查点，同名检查点会被覆盖
+func (sh *sceneHistory) save(name string, by string) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if len(sh.states) == 0 {
+		return false
+	}
+	if _, exists := sh.checkpoints[name]; !exists && len(sh.checkpoints) >= maxCheckpoints {
+		return false
+	}
+	sh.checkpoints[name] = historyEntry{
+		Label: name,
+		By:    by,
+		Time:  utils.NowMillis(),
+		state: sh.states[sh.cursor].state,
+	}
+	return true
+}
+
+// historyState 历史状态推送，供教师端显示撤销、重做按钮和检查点列表
+type historyState struct {
+	Undo        int            `json:"undo"` // 可撤销的步数
+	Redo        int            `json:"redo"` // 可重做的步数
+	Current     string         `json:"current"`
+	Checkpoints []historyEntry `json:"checkpoints"`
+}
+
+// status 返回当前历史状态，检查点按保存时间排序
+func (sh *sceneHistory) status() historyState {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	state := historyState{Checkpoints: make([]historyEntry, 0, len(sh.checkpoints))}
+	if len(sh.states) > 0 {
+		state.Undo = sh.cursor
+		state.Redo = len(sh.states) - 1 - sh.cursor
+		state.Current = sh.states[sh.cursor].Label
+	}
+	for _, entry := range sh.checkpoints {
+		state.Checkpoints = append(state.Checkpoints, entry)
+	}
+	sort.Slice(state.Checkpoints, func(i, j int) bool {
+		return state.Checkpoints[i].Time < state.Checkpoints[j].Time
+	})
+	return state
+}
+
+// sceneChanged 授课模式下共享场景发生变化后记录历史
+func (h *Hub) sceneChanged(scene string, by string, label string) {
+	if scene != sharedScene {
+		return
+	}
+	if _, mode := h.courseDetail.Course(); models.CourseMode(mode) != models.TeachingMode {
+		return
+	}
+	if h.history.push(label, by, h.courseDetail.snapshot) {
+		h.publishHistory()
+	}
+}
+
+// resetHistory 课程开始或结束时以当前共享场景为起点重置历史
+func (h *Hub) resetHistory() {
+	h.history.reset(h.courseDetail.snapshot())
+}
+
+// historyRequest 撤销、重做或检查点请求
+type historyRequest struct {
+	Steps int    `json:"steps"` // 撤销或重做的步数，默认 1
+	Name  string `json:"name"`  // 检查点名称
+}
+
+// requireTeachingScene 校验教师在授课模式下操作共享场景历史
+func (c *Client) requireTeachingScene() bool {
+	if !c.requireTeacher() {
+		return false
+	}
+	if _, mode := c.hub.courseDetail.Course(); models.CourseMode(mode) != models.TeachingMode {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return false
+	}
+	return true
+}
+
+// handleHistory 处理撤销、重做、保存和恢复检查点消息
+func (c *Client) handleHistory(msgType int32, data interface{}) {
+	if !c.requireTeachingScene() {
+		return
+	}
+
+	var req historyRequest
+	if err := decodeData(data, &req); err != nil || req.Steps < 0 {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	if req.Steps == 0 {
+		req.Steps = 1
+	}
+
+	switch msgType {
+	case protocol.HistoryUndo, protocol.HistoryRedo:
+		if msgType == protocol.HistoryUndo {
+			req.Steps = -req.Steps
+		}
+		moved := c.hub.history.move(req.Steps, c.hub.courseDetail.restore)
+		if moved == 0 {
+			c.sendErrorResponse(e.ErrNothingToUndo)
+			return
+		}
+		c.hub.logger.Info("Scene history moved",
+			zap.String("teacher", c.user.ID),
+			zap.Int("steps", moved))
+
+	case protocol.CheckpointSave:
+		if req.Name == "" || len(req.Name) > 64 {
+			c.sendErrorResponse(e.ErrInvalidData)
+			return
+		}
+		if !c.hub.history.save(req.Name, c.user.ID) {
+			c.sendErrorResponse(e.ErrInvalidData)
+			return
+		}
+		c.hub.publishHistory()
+		return
+
+	case protocol.CheckpointRestore:
+		if !c.hub.history.restoreCheckpoint(req.Name, c.user.ID, c.hub.courseDetail.snapshot, c.hub.courseDetail.restore) {
+			c.sendErrorResponse(e.ErrCheckpointNotFound)
+			return
+		}
+		c.hub.logger.Info("Scene checkpoint restored",
+			zap.String("teacher", c.user.ID),
+			zap.String("checkpoint", req.Name))
+	}
+
+	// 恢复后的完整状态推送给共享场景内的客户端
+	c.hub.broadcastScene(sharedScene, protocol.Message{
+		Type:       protocol.CourseDetail,
+		DeviceCode: c.user.ID,
+		Data:       c.hub.courseDetail,
+	})
+	c.hub.publishHistory()
+}
+
+// publishHistory 向教师和观察者推送历史状态
+func (h *Hub) publishHistory() {
+	h.sendTo((*Client).isStaff, marshalMessage(protocol.Message{
+		Type: protocol.HistoryState,
+		Data: h.history.status(),
+	}))
+}
@@ -8,13 +8,18 @@ import (
 
 	// "strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"xnfz/api"
 	"xnfz/internal/course"
+	"xnfz/internal/device"
 	e "xnfz/internal/errors"
+	"xnfz/internal/scoring"
+	"xnfz/internal/script"
 	"xnfz/internal/session"
 	"xnfz/pkg/models"
+	"xnfz/pkg/utils"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -22,10 +27,11 @@ import (
 
 // 常量定义
 const (
-	writeWait      = 10 * time.Second    // 写操作超时时间
-	pongWait       = 60 * time.Second    // 等待 pong 消息的最大时间
-	pingPeriod     = (pongWait * 9) / 10 // 发送 ping 消息的周期
-	maxMessageSize = 512                 // 最大消息大小
+	writeWait      = 10 * time.Second      // 写操作超时时间
+	pongWait       = 60 * time.Second      // 等待 pong 消息的最大时间
+	pingPeriod     = (pongWait * 9) / 10   // 发送 ping 消息的周期
+	maxMessageSize = 512                   // 最大消息大小
+	tickPeriod     = 50 * time.Millisecond // Hub 周期任务（跟随模式推送等）的间隔
 )
 
 // var (
@@ -47,39 +53,14 @@ const (
 
 // WebSocket 连接升级器
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	ReadBufferSize:    1024,
+	WriteBufferSize:   1024,
+	EnableCompression: true, // 仅在客户端声明 deflate 能力后启用写压缩
 	CheckOrigin: func(r *http.Request) bool {
 		return true // 注意：在生产环境中应该实现更安全的源检查
 	},
 }
 
-// CourseDetail 存储课程详情
-type CourseDetail struct {
-	CourseID string                `json:"courseId"`
-	Mode     int32                 `json:"mode"`
-	Data     map[int32]interface{} `json:"data"`
-	mu       sync.RWMutex
-}
-
-// GetDataAttachment 安全地获取 DataAttachment
-func (cd *CourseDetail) GetDataAttachment() map[int32]interface{} {
-	cd.mu.RLock()
-	defer cd.mu.RUnlock()
-	result := make(map[int32]interface{})
-	for k, v := range cd.Data {
-		result[k] = v
-	}
-	return result
-}
-
-// UpdateDataAttachment 安全地更新 DataAttachment
-func (cd *CourseDetail) UpdateDataAttachment(update func(map[int32]interface{})) {
-	cd.mu.Lock()
-	defer cd.mu.Unlock()
-	update(cd.Data)
-}
-
 // Client 表示一个 WebSocket 客户端连接
 type Client struct {
 	hub     *Hub
@@ -88,6 +69,120 @@ type Client struct {
 	session *session.Session
 	user    *models.User
 	isMain  bool
+	clock   *clockSync
+	mu      sync.RWMutex
+
+	assetCourse string // 资源包校验通过的课程
+
+	hello        clientHello     // 握手时上报的客户端信息
+	capabilities map[string]bool // 握手协商启用的能力
+
+	closeCode   int    // 服务器主动断开时的关闭原因码
+	closeReason string // 服务器主动断开时的关闭原因
+}
+
+// getSession 安全地获取客户端当前会话
+func (c *Client) getSession() *session.Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.session
+}
+
+// sendMessage 向客户端发送消息，客户端已断开或发送队列已满时丢弃
+func (c *Client) sendMessage(msg protocol.Message) {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+
+	if !c.hub.clients[c] {
+		return
+	}
+	select {
+	case c.send <- marshalMessage(msg):
+	default:
+		c.hub.logger.Warn("Send buffer full, message dropped", zap.String("deviceCode", c.user.ID))
+	}
+}
+
+// disconnect 以指定原因码断开客户端
+func (c *Client) disconnect(code int, reason string) {
+	c.mu.Lock()
+	c.closeCode = code
+	c.closeReason = reason
+	c.mu.Unlock()
+	c.hub.unregister <- c
+}
+
+// closeMessage 返回关闭连接时发送的关闭帧内容
+func (c *Client) closeMessage() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closeCode == 0 {
+		return []byte{}
+	}
+	return websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+}
+
+// startSession 结束客户端的旧会话，并为指定课程创建新会话
+func (c *Client) startSession(course *models.Course) *session.Session {
+	c.mu.Lock()
+	if c.session != nil {
+		c.hub.sessions.EndSession(c.session.ID)
+	}
+	s := c.hub.sessions.CreateSession(c.user, course)
+	c.session = s
+	c.mu.Unlock()
+
+	c.hub.initSteps(s)
+	c.hub.publishPresence(protocol.PresenceUpdate, c.participant())
+	return s
+}
+
+// endSessions 结束除 except 外所有客户端的会话，之后按需重新创建
+func (h *Hub) endSessions(except *Client) {
+	for _, client := range h.clientsWhere(allClients) {
+		if client == except {
+			continue
+		}
+		if s := client.getSession(); s != nil {
+			h.sessions.EndSession(s.ID)
+			client.setSession(nil)
+		}
+	}
+}
+
+// ensureSession 返回客户端当前会话，没有会话时为正在进行的课程创建一个
+func (c *Client) ensureSession() *session.Session {
+	if s := c.getSession(); s != nil {
+		return s
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	course, ok := c.hub.courses.GetCourse(courseID)
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	if c.session != nil {
+		s := c.session
+		c.mu.Unlock()
+		return s
+	}
+	s := c.hub.sessions.CreateSession(c.user, course)
+	c.session = s
+	c.mu.Unlock()
+
+	c.hub.initSteps(s)
+	c.hub.publishPresence(protocol.PresenceUpdate, c.participant())
+	return s
+}
+
+// setSession 安全地设置客户端当前会话
+func (c *Client) setSession(s *session.Session) {
+	c.mu.Lock()
+	c.session = s
+	c.mu.Unlock()
 }
 
 // Hub 维护活动客户端的集合并广播消息
@@ -100,54 +195,194 @@ type Hub struct {
 	courses      *course.Manager
 	logger       *zap.Logger
 	courseDetail *CourseDetail
+	config       Config
+	classroom    *classroomControls
+	scenes       *sceneRegistry
+	groups       *groupRegistry
+	progress     *progressTracker
+	steps        *classSteps
+	script       *courseScript
+	quizzes      *quizRegistry
+	help         *helpQueue
+	chat         *chatLog
+	avatars      *avatarPoses
+	anchors      *anchorRegistry
+	history      *sceneHistory
+	devices      *device.Registry
+	networkIDs   atomic.Int32 // 运行时生成对象的网络 ID 计数
+	mu           sync.RWMutex // 保护 clients，仅 Run 修改
 }
 
 // NewHub 创建一个新的 Hub
-func NewHub(sessions *session.Manager, courses *course.Manager, logger *zap.Logger) *Hub {
+func NewHub(sessions *session.Manager, courses *course.Manager, logger *zap.Logger, config Config) *Hub {
+	anchors, err := newAnchorRegistry(config.AnchorFile)
+	if err != nil {
+		logger.Error("Failed to load anchors", zap.String("file", config.AnchorFile), zap.Error(err))
+	}
+	devices := config.Devices
+	if devices == nil {
+		devices, _ = device.NewRegistry("", logger)
+	}
+
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		sessions:   sessions,
-		courses:    courses,
-		logger:     logger,
-		courseDetail: &CourseDetail{
-			Data: make(map[int32]interface{}),
-		},
+		clients:      make(map[*Client]bool),
+		broadcast:    make(chan []byte),
+		register:     make(chan *Client),
+		unregister:   make(chan *Client),
+		sessions:     sessions,
+		courses:      courses,
+		logger:       logger,
+		courseDetail: NewCourseDetail("", 0),
+		config:       config,
+		classroom:    newClassroomControls(),
+		scenes:       newSceneRegistry(),
+		groups:       newGroupRegistry(),
+		progress:     newProgressTracker(),
+		steps:        &classSteps{},
+		script:       &courseScript{},
+		quizzes:      newQuizRegistry(),
+		help:         &helpQueue{},
+		chat:         newChatLog(config.ChatHistory),
+		avatars:      newAvatarPoses(),
+		anchors:      anchors,
+		history:      newSceneHistory(config.UndoHistory),
+		devices:      devices,
 	}
 }
 
 // Run 启动 Hub 的主循环
 func (h *Hub) Run() {
+	ticker := time.NewTicker(tickPeriod)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
+			h.mu.Lock()
 			h.clients[client] = true
+			h.mu.Unlock()
+			if client.user.Role == models.Student {
+				h.progress.track(client.user.ID)
+			}
+			h.publishPresence(protocol.PresenceJoin, client.participant())
 		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				if client.session != nil {
-					h.sessions.EndSession(client.session.ID)
-				}
+			h.mu.Lock()
+			_, ok := h.clients[client]
+			if ok {
+				h.removeClient(client)
+			}
+			h.mu.Unlock()
+			if ok {
+				h.clientLeft(client)
 			}
 		case message := <-h.broadcast:
+			var dropped []*Client
+			h.mu.Lock()
 			for client := range h.clients {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
-					delete(h.clients, client)
-					if client.session != nil {
-						h.sessions.EndSession(client.session.ID)
-					}
+					h.removeClient(client)
+					dropped = append(dropped, client)
 				}
 			}
+			h.mu.Unlock()
+			for _, client := range dropped {
+				h.clientLeft(client)
+			}
+		case <-ticker.C:
+			h.tick()
 		}
 	}
 }
 
+// tick 执行 Hub 的周期任务，不能阻塞
+func (h *Hub) tick() {
+	h.flushFollowView()
+	h.flushDashboard()
+	h.flushQuizzes()
+	h.flushAvatars()
+}
+
+// clientLeft 在客户端被移除后（已释放 h.mu）结束其会话并通知其他客户端
+func (h *Hub) clientLeft(client *Client) {
+	// 进度按客户端当前所在的场景保存，需要在重置场景之前完成
+	if s := client.getSession(); s != nil {
+		h.saveProgress(client, s)
+		h.sessions.EndSession(s.ID)
+	}
+	h.publishPresence(protocol.PresenceLeave, client.participant())
+	if h.classroom.releaseLeader(client.user.ID) {
+		h.broadcastClassroomState()
+	}
+	h.scenes.observe(client.user.ID, sharedScene)
+	h.releaseTalk(client)
+	if len(h.clientsByUser(client.user.ID)) == 0 {
+		h.avatars.remove(client.user.ID)
+		h.progress.remove(client.user.ID)
+	}
+	if len(h.clientsByUser(client.user.ID)) == 0 && len(h.help.remove(client.user.ID)) > 0 {
+		h.publishHelpQueue(nil)
+	}
+	h.devices.Disconnected(client.user.ID)
+	go h.invokeScript(script.HookLeave, client.user.ID)
+}
+
+// removeClient 移除客户端，调用方需持有 h.mu 写锁
+// 会话在 clientLeft 中结束，进度和会话记录由 session.Manager 在后台写入文件
+func (h *Hub) removeClient(client *Client) {
+	delete(h.clients, client)
+	close(client.send)
+}
+
+// allClients 匹配所有客户端的过滤器
+func allClients(*Client) bool {
+	return true
+}
+
+// sendTo 向满足条件的客户端发送消息，发送队列已满的客户端将被跳过
+func (h *Hub) sendTo(filter func(*Client) bool, message []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if !filter(client) {
+			continue
+		}
+		select {
+		case client.send <- message:
+		default:
+			h.logger.Warn("Send buffer full, message dropped", zap.String("deviceCode", client.user.ID))
+		}
+	}
+}
+
+// clientsWhere 返回满足条件的所有在线客户端
+func (h *Hub) clientsWhere(filter func(*Client) bool) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var result []*Client
+	for client := range h.clients {
+		if filter(client) {
+			result = append(result, client)
+		}
+	}
+	return result
+}
+
+// clientsByUser 返回指定用户的所有在线客户端
+func (h *Hub) clientsByUser(userID string) []*Client {
+	return h.clientsWhere(func(client *Client) bool {
+		return client.user.ID == userID
+	})
+}
+
+// isStudent 匹配学生客户端的过滤器
+func isStudent(c *Client) bool {
+	return c.user.Role == models.Student
+}
+
 // readPump 从 WebSocket 连接中泵取消息
 func (c *Client) readPump() {
 	defer func() {
@@ -180,16 +415,102 @@ func (c *Client) readPump() {
 		switch msg.Type {
 		case protocol.Heartbeat:
 			c.handleHeartbeat()
+		case protocol.TimeSync:
+			c.handleTimeSync(msg.Data)
 		case protocol.CourseSelection:
 			c.handleCourseSelection(c.user.ID, msg.Data)
 		case protocol.CourseModeSelection:
 			c.handleCourseModeSelection(c.user.ID, msg.Data)
 		case protocol.ObjectManipulation:
 			c.handleObjectManipulation(c.user.ID, msg.Data)
+		case protocol.ObjectSpawn:
+			c.handleObjectSpawn(msg.Data)
+		case protocol.ObjectDestroy:
+			c.handleObjectDestroy(msg.Data)
+		case protocol.ObjectReparent:
+			c.handleObjectReparent(msg.Data)
+		case protocol.HistoryUndo, protocol.HistoryRedo, protocol.CheckpointSave, protocol.CheckpointRestore:
+			c.handleHistory(msg.Type, msg.Data)
+		case protocol.ProgressResume:
+			c.handleProgressResume(msg.Data)
+		case protocol.ProgressReset:
+			c.handleProgressReset(msg.Data)
+		case protocol.DeviceTelemetry:
+			c.handleDeviceTelemetry(msg.Data)
+		case protocol.AssetCheck:
+			c.handleAssetCheck(msg.Data)
+		case protocol.PropertySet, protocol.PropertyPatch:
+			c.handleObjectProperties(msg.Type, msg.Data)
 		case protocol.CourseEnd:
 			c.handleEndCourse(c.user.ID, msg.Data)
 		case protocol.CourseExit:
 			c.handleExitCourse(c.user.ID)
+		case protocol.RosterRequest:
+			c.handleRosterRequest()
+		case protocol.ClassroomFreeze:
+			c.handleClassroomFreeze(msg.Data)
+		case protocol.ClassroomMute:
+			c.handleClassroomMute(msg.Data)
+		case protocol.ClassroomKick:
+			c.handleClassroomKick(msg.Data)
+		case protocol.FollowMe:
+			c.handleFollowMe(msg.Data)
+		case protocol.FollowMeTransform:
+			c.handleFollowMeTransform(msg.Data)
+		case protocol.ChatAnnouncement:
+			c.handleChatAnnouncement(msg.Data)
+		case protocol.ChatPrivate:
+			c.handleChatPrivate(msg.Data)
+		case protocol.RTCOffer, protocol.RTCAnswer, protocol.RTCCandidate, protocol.RTCHangup:
+			c.handleRTCSignal(msg.Type, msg.Data)
+		case protocol.VoiceTalk:
+			c.handleVoiceTalk(msg.Data)
+		case protocol.AvatarPose:
+			c.handleAvatarPose(msg.Data)
+		case protocol.AnchorSet:
+			c.handleAnchorSet(msg.Data)
+		case protocol.AnchorRequest:
+			c.handleAnchorRequest()
+		case protocol.HelpRaise:
+			c.handleHelpRaise(msg.Data)
+		case protocol.HelpAcknowledge:
+			c.handleHelpAcknowledge(msg.Data)
+		case protocol.HelpClear:
+			c.handleHelpClear(msg.Data)
+		case protocol.WorkspaceObserve:
+			c.handleWorkspaceObserve(msg.Data)
+		case protocol.WorkspaceDemonstrate:
+			c.handleWorkspaceDemonstrate(msg.Data)
+		case protocol.GroupCreate:
+			c.handleGroupCreate(msg.Data)
+		case protocol.GroupMove:
+			c.handleGroupMove(msg.Data)
+		case protocol.GroupMerge:
+			c.handleGroupMerge()
+		case protocol.ProgressReport:
+			c.handleProgressReport(msg.Data)
+		case protocol.DashboardRequest:
+			c.handleDashboardRequest()
+		case protocol.StepStart:
+			c.handleStepStart(msg.Data)
+		case protocol.StepComplete:
+			c.handleStepComplete(msg.Data)
+		case protocol.StepSkip:
+			c.handleStepSkip(msg.Data)
+		case protocol.FaultInject:
+			c.handleFaultInject(msg.Data)
+		case protocol.FaultClear:
+			c.handleFaultClear(msg.Data)
+		case protocol.QuizStart:
+			c.handleQuizStart(msg.Data)
+		case protocol.QuizAnswer:
+			c.handleQuizAnswer(msg.Data)
+		case protocol.QuizClose:
+			c.handleQuizClose(msg.Data)
+		case protocol.ScriptEvent:
+			c.handleScriptEvent(msg.Data)
+		case protocol.StepAdvance:
+			c.handleStepAdvance(msg.Data)
 		}
 	}
 }
@@ -201,6 +522,8 @@ func authenticateUser(r *http.Request) *models.User {
 	role := models.Student
 	if r.URL.Query().Get("main") == "true" {
 		role = models.Teacher
+	} else if r.URL.Query().Get("role") == "observer" {
+		role = models.Observer
 	}
 	return &models.User{
 		ID:   deviceCode,
@@ -215,7 +538,7 @@ func (c *Client) handleHeartbeat() {
 		Type: protocol.HeartbeatResponse,
 		Data: "pong",
 	}
-	c.send <- marshalMessage(response)
+	c.sendMessage(response)
 }
 
 // handleCourseSelection 处理课程选择消息
@@ -232,15 +555,19 @@ func (c *Client) handleCourseSelection(deviceCode string, data interface{}) {
 		return
 	}
 
-	course := c.hub.courses.CreateCourse(courseID, "", "", 0, time.Duration(time.Now().Second()))
-
-	if c.session != nil {
-		c.hub.sessions.EndSession(c.session.ID)
+	// 已从课程定义加载的课程直接使用，否则创建一个空课程
+	course, found := c.hub.courses.GetCourse(courseID)
+	if !found {
+		course = c.hub.courses.CreateCourse(courseID, "", "", 0, time.Duration(time.Now().Second()))
 	}
 
-	c.session = c.hub.sessions.CreateSession(c.user, course)
+	currentID, mode := c.hub.courseDetail.Course()
+	if currentID != courseID {
+		c.hub.unloadScript()
+	}
+	c.hub.courseDetail.SetCourse(courseID, mode)
 
-	c.hub.courseDetail.CourseID = courseID
+	c.startSession(course)
 
 	response := protocol.Message{
 		Type: protocol.CourseSelected,
@@ -283,16 +610,19 @@ func (c *Client) handleCourseModeSelection(deviceCode string, data interface{})
 		return
 	}
 
-	c.hub.courses.UpdateCourse(courseID, "", "", models.CourseMode(mode), time.Duration(time.Now().Second()))
-
-	if c.session != nil {
-		c.hub.sessions.EndSession(c.session.ID)
-	}
+	c.hub.courses.UpdateCourse(courseID, course.Name, course.Description, models.CourseMode(mode), course.Duration)
 
-	c.session = c.hub.sessions.CreateSession(c.user, course)
+	c.hub.courseDetail.SetCourse(courseID, int32(mode))
+	c.hub.scenes.reset()
+	c.hub.progress.reset()
+	c.hub.steps.set("")
+	c.hub.quizzes.reset()
+	c.hub.resetHistory()
 
-	c.hub.courseDetail.CourseID = courseID
-	c.hub.courseDetail.Mode = int32(mode)
+	// 课程重新开始，其他参与者的旧会话作废
+	c.hub.endSessions(c)
+	c.startSession(course)
+	c.hub.loadScript(course)
 
 	response := protocol.Message{
 		Type: protocol.CourseStart,
@@ -305,12 +635,25 @@ func (c *Client) handleCourseModeSelection(deviceCode string, data interface{})
 		response.DeviceCode = deviceCode
 	}
 	c.hub.broadcast <- marshalMessage(response)
+	c.hub.publishAssetManifest(courseID)
+
+	for _, client := range c.hub.clientsWhere(isStudent) {
+		c.hub.offerProgress(client)
+	}
 }
 
 // handleObjectManipulation 处理对象操作消息
 func (c *Client) handleObjectManipulation(deviceCode string, data interface{}) {
 	// c.hub.logger.Info(fmt.Sprintf("manipulation data:%+v", data))
 
+	if c.hub.classroom.isFrozen(c) {
+		c.sendErrorResponse(e.ErrInteractionFrozen)
+		return
+	}
+	if !c.requireAssets() {
+		return
+	}
+
 	dataMap, ok := data.(map[string]interface{})
 	if !ok {
 		c.hub.logger.Error("Invalid data format")
@@ -340,7 +683,19 @@ func (c *Client) handleObjectManipulation(deviceCode string, data interface{}) {
 	// Continue with your logic here
 	c.hub.logger.Info(fmt.Sprintf("Processed data: %+v", processedData))
 
-	// Example: Broadcast the processed data
+	// 分组学生更新分组场景，练习模式下学生只更新自己的工作区，其余情况更新共享场景
+	scene := c.hub.sceneOf(c)
+	processedData = c.validateManipulation(scene, processedData)
+	if len(processedData) == 0 {
+		return
+	}
+	c.hub.sceneDetail(scene).Merge(processedData)
+	if c.user.Role == models.Student {
+		c.hub.progress.interact(c.user.ID)
+	}
+	c.recordManipulation(processedData)
+	c.hub.sceneChanged(scene, c.user.ID, "manipulate")
+
 	response := protocol.Message{
 		Type: protocol.ObjectManipulation,
 		// DeviceCode: c.user.ID,
@@ -349,23 +704,25 @@ func (c *Client) handleObjectManipulation(deviceCode string, data interface{}) {
 	if deviceCode == c.user.ID {
 		response.DeviceCode = deviceCode
 	}
-	c.hub.broadcast <- marshalMessage(response)
+	c.hub.broadcastScene(scene, response)
+	c.hub.scriptManipulation(c.user.ID, processedData)
 }
 
 // handleExitCourse 处理结束课程消息
 func (c *Client) handleEndCourse(deviceCode string, data interface{}) {
-	if c.session != nil {
-		c.hub.sessions.EndSession(c.session.ID)
-		c.session = nil
+	hadSession := c.getSession() != nil
+	c.hub.finishSessions()
+	if hadSession {
+		c.hub.publishPresence(protocol.PresenceUpdate, c.participant())
 	}
 
-	c.hub.courseDetail.CourseID = ""
-	c.hub.courseDetail.Mode = 0
-	c.hub.courseDetail.UpdateDataAttachment(func(dataAttachment map[int32]interface{}) {
-		for k := range dataAttachment {
-			delete(dataAttachment, k)
-		}
-	})
+	c.hub.courseDetail.Reset()
+	c.hub.scenes.reset()
+	c.hub.steps.set("")
+	c.hub.quizzes.reset()
+	c.hub.resetHistory()
+	c.hub.unloadScript()
+	c.hub.publishHelpQueue(c.hub.help.remove(""))
 
 	response := protocol.Message{
 		Type: protocol.CourseEnd,
@@ -383,18 +740,19 @@ func (c *Client) handleEndCourse(deviceCode string, data interface{}) {
 
 // handleExitCourse 处理退出课程消息
 func (c *Client) handleExitCourse(deviceCode string) {
-	if c.session != nil {
-		c.hub.sessions.EndSession(c.session.ID)
-		c.session = nil
+	if s := c.getSession(); s != nil {
+		c.hub.sessions.EndSession(s.ID)
+		c.setSession(nil)
+		c.hub.publishPresence(protocol.PresenceUpdate, c.participant())
 	}
 
-	c.hub.courseDetail.CourseID = ""
-	c.hub.courseDetail.Mode = 0
-	c.hub.courseDetail.UpdateDataAttachment(func(dataAttachment map[int32]interface{}) {
-		for k := range dataAttachment {
-			delete(dataAttachment, k)
-		}
-	})
+	c.hub.courseDetail.Reset()
+	c.hub.scenes.reset()
+	c.hub.steps.set("")
+	c.hub.quizzes.reset()
+	c.hub.resetHistory()
+	c.hub.unloadScript()
+	c.hub.publishHelpQueue(c.hub.help.remove(""))
 
 	response := protocol.Message{
 		Type: protocol.CourseExit,
@@ -415,7 +773,19 @@ func (c *Client) sendErrorResponse(err e.ErrorMessage) {
 		Code: err.Code,
 		Data: err.Message,
 	}
-	c.send <- marshalMessage(response)
+	c.sendMessage(response)
+}
+
+// recordMistake 记录学生的操作错误（步骤条件不满足、不合法的对象操作），计入进度看板和评分
+// 权限、冻结、格式等协议错误不是学生的操作失误，不应调用
+func (c *Client) recordMistake() {
+	if c.user.Role != models.Student {
+		return
+	}
+	c.hub.progress.addErrors(c.user.ID, 1)
+	if s := c.getSession(); s != nil {
+		s.Track(scoring.Event{Kind: scoring.EventError})
+	}
 }
 
 // // startPracticeTimer 启动实践模式计时器
@@ -435,8 +805,11 @@ func (c *Client) sendErrorResponse(err e.ErrorMessage) {
 // 	}
 // }
 
-// marshalMessage 将消息序列化为 JSON
+// marshalMessage 将消息序列化为 JSON，并附加服务器时间戳
 func marshalMessage(msg protocol.Message) []byte {
+	if msg.ServerTime == 0 {
+		msg.ServerTime = utils.NowMillis()
+	}
 	data, _ := json.Marshal(msg)
 	return data
 }
@@ -455,25 +828,177 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hello, ok := hub.handshake(conn, user)
+	if !ok {
+		return
+	}
+
+	if !hub.checkKicked(conn, user) || !hub.checkClassroom(conn, user) {
+		return
+	}
+
+	if !hub.enforceDuplicatePolicy(conn, user) {
+		return
+	}
+	hub.devices.Connected(user.ID, hello.AppVersion, hello.Platform)
+
 	client := &Client{
-		hub:    hub,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		user:   user,
-		isMain: r.URL.Query().Get("main") == "true",
+		hub:          hub,
+		conn:         conn,
+		send:         make(chan []byte, 256),
+		user:         user,
+		isMain:       r.URL.Query().Get("main") == "true",
+		clock:        newClockSync(),
+		hello:        hello,
+		capabilities: hello.negotiate(),
+	}
+	conn.EnableWriteCompression(client.supports(protocol.CapDeflate))
+
+	hub.logger.Info("Client connected",
+		zap.String("deviceCode", user.ID),
+		zap.String("appVersion", hello.AppVersion),
+		zap.String("platform", hello.Platform),
+		zap.Strings("capabilities", hello.Capabilities))
+
+	// 入场快照在注册前写入发送队列：注册后 Hub 可能随时关闭发送队列（被替换、队列已满）
+	for _, msg := range hub.joinMessages(client) {
+		select {
+		case client.send <- marshalMessage(msg):
+		default:
+			hub.logger.Warn("Send buffer full, message dropped", zap.String("deviceCode", user.ID))
+		}
 	}
 	client.hub.register <- client
 
-	if hub.courseDetail.CourseID != "" {
+	hub.invokeScript(script.HookJoin, user.ID)
+
+	go client.readPump()
+	go client.writePump()
+}
+
+// joinMessages 返回新加入的客户端需要的入场快照：握手响应、锚点、课程场景及课堂状态
+func (h *Hub) joinMessages(client *Client) []protocol.Message {
+	var messages []protocol.Message
+	if h.config.RequireHello {
+		messages = append(messages, client.welcome())
+	}
+
+	// 空间锚点需要在课程对象之前送达，客户端先对齐坐标再放置对象
+	if anchors := h.anchors.state(); len(anchors.Anchors) > 0 {
+		anchorMessage := protocol.Message{
+			Type: protocol.AnchorState,
+			Data: anchors,
+		}
+		messages = append(messages, anchorMessage)
+	}
+
+	if courseID, _ := h.courseDetail.Course(); courseID != "" {
+		// 资源清单先于课程对象送达，客户端据此检查并下载资源包
+		if manifestMessage, ok := h.assetManifest(courseID); ok {
+			messages = append(messages, manifestMessage)
+		}
 		detailMessage := protocol.Message{
 			Type: protocol.CourseDetail,
-			Data: hub.courseDetail,
+			Data: h.sceneDetail(h.sceneOf(client)),
 		}
-		client.send <- marshalMessage(detailMessage)
+		messages = append(messages, detailMessage)
 	}
 
-	go client.readPump()
-	go client.writePump()
+	stateMessage := protocol.Message{
+		Type: protocol.ClassroomState,
+		Data: h.classroom.state(),
+	}
+	messages = append(messages, stateMessage)
+
+	if groups := h.groups.list(); len(groups) > 0 {
+		groupMessage := protocol.Message{
+			Type: protocol.GroupState,
+			Data: groups,
+		}
+		messages = append(messages, groupMessage)
+	}
+
+	if stepID := h.steps.get(); stepID != "" && h.teaching() {
+		courseID, _ := h.courseDetail.Course()
+		if course, ok := h.courses.GetCourse(courseID); ok {
+			if index := course.StepIndex(stepID); index >= 0 {
+				stepMessage := protocol.Message{
+					Type: protocol.StepAdvance,
+					Data: stepAdvanceMessage{StepID: stepID, Title: course.Steps[index].Title},
+				}
+				messages = append(messages, stepMessage)
+			}
+		}
+	}
+
+	if history := h.chat.visibleTo(client); len(history) > 0 {
+		historyMessage := protocol.Message{
+			Type: protocol.ChatHistory,
+			Data: history,
+		}
+		messages = append(messages, historyMessage)
+	}
+
+	if queue := h.help.list(); len(queue) > 0 && client.isStaff() {
+		helpMessage := protocol.Message{
+			Type: protocol.HelpQueue,
+			Data: queue,
+		}
+		messages = append(messages, helpMessage)
+	}
+
+	if _, mode := h.courseDetail.Course(); models.CourseMode(mode) == models.TeachingMode && client.isStaff() {
+		sceneHistoryMessage := protocol.Message{
+			Type: protocol.HistoryState,
+			Data: h.history.status(),
+		}
+		messages = append(messages, sceneHistoryMessage)
+	}
+
+	for _, view := range h.openQuizzes() {
+		quizMessage := protocol.Message{
+			Type: protocol.QuizStart,
+			Data: view,
+		}
+		messages = append(messages, quizMessage)
+	}
+
+	if offer, ok := h.pendingProgress(client); ok {
+		offerMessage := protocol.Message{
+			Type:       protocol.ProgressOffer,
+			DeviceCode: client.user.ID,
+			Data:       offer,
+		}
+		messages = append(messages, offerMessage)
+	}
+
+	return messages
+}
+
+// enforceDuplicatePolicy 按配置处理同一 deviceCode 的重复连接，返回新连接是否可以继续
+func (h *Hub) enforceDuplicatePolicy(conn *websocket.Conn, user *models.User) bool {
+	existing := h.clientsByUser(user.ID)
+	if len(existing) == 0 {
+		return true
+	}
+
+	switch h.config.DuplicatePolicy {
+	case DuplicateRejectNew:
+		h.logger.Warn("Duplicate device rejected", zap.String("deviceCode", user.ID))
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(protocol.CloseDuplicateRejected, "device already connected"),
+			time.Now().Add(writeWait))
+		conn.Close()
+		return false
+	case DuplicateAllow:
+		return true
+	default:
+		for _, old := range existing {
+			h.logger.Info("Duplicate device, displacing old connection", zap.String("deviceCode", user.ID))
+			old.disconnect(protocol.CloseDisplaced, "device connected from another session")
+		}
+		return true
+	}
 }
 
 // writePump 将消息泵送到 WebSocket 连接
@@ -489,11 +1014,11 @@ func (c *Client) writePump() {
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// 通道已关闭
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage, c.closeMessage())
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
+			w, err := c.conn.NextWriter(c.frameType())
 			if err != nil {
 				return
 			}
@@ -507,7 +1032,7 @@ func (c *Client) writePump() {
 			// 检查是否有更多消息等待发送
 			n := len(c.send)
 			for i := 0; i < n; i++ {
-				w, err := c.conn.NextWriter(websocket.TextMessage)
+				w, err := c.conn.NextWriter(c.frameType())
 				if err != nil {
 					return
 				}
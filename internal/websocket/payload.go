@@ -0,0 +1,14 @@
+package websocket
+
+import (
+	"encoding/json"
+)
+
+// decodeData 将消息中的 data 字段解码为指定的结构体
+func decodeData(data interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,223 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+)
+
+const dashboardPeriod = time.Second // 进度看板推送的最小间隔
+
+// studentProgress 单个学生的练习进度
+type studentProgress struct {
+	stepsCompleted  int
+	totalSteps      int
+	errors          int
+	manipulations   int
+	lastInteraction time.Time
+	idle            bool
+}
+
+// dashboardRow 进度看板中的一行，字段名保持简短以减小推送体积
+type dashboardRow struct {
+	DeviceCode      string `json:"d"`
+	StepsCompleted  int    `json:"s"`
+	TotalSteps      int    `json:"t"`
+	Errors          int    `json:"e"`
+	Manipulations   int    `json:"m"`
+	LastInteraction int64  `json:"l"`
+	Idle            bool   `json:"i"`
+}
+
+// progressReportRequest 学生上报的练习进度，仅用于课程未定义步骤时
+// 错误次数只统计服务器判定的操作错误，不接受客户端上报
+type progressReportRequest struct {
+	StepsCompleted int `json:"stepsCompleted"`
+	TotalSteps     int `json:"totalSteps"`
+}
+
+// progressTracker 汇总学生练习进度
+type progressTracker struct {
+	entries  map[string]*studentProgress
+	dirty    bool
+	lastPush time.Time // 仅在 Hub.Run 中访问
+	mu       sync.Mutex
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		entries: make(map[string]*studentProgress),
+	}
+}
+
+// entry 获取学生进度，不存在时创建，调用方需持有锁
+func (pt *progressTracker) entry(userID string) *studentProgress {
+	p, ok := pt.entries[userID]
+	if !ok {
+		p = &studentProgress{lastInteraction: time.Now()}
+		pt.entries[userID] = p
+		pt.dirty = true
+	}
+	return p
+}
+
+// track 开始跟踪学生进度
+func (pt *progressTracker) track(userID string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.entry(userID)
+}
+
+// interact 记录一次对象操作
+func (pt *progressTracker) interact(userID string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	p := pt.entry(userID)
+	p.manipulations++
+	p.lastInteraction = time.Now()
+	pt.dirty = true
+}
+
+// addErrors 累加错误次数
+func (pt *progressTracker) addErrors(userID string, n int) {
+	if n <= 0 {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.entry(userID).errors += n
+	pt.dirty = true
+}
+
+// setSteps 更新已完成步骤数和总步骤数，total 为 0 时保持原值
+func (pt *progressTracker) setSteps(userID string, completed int, total int) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	p := pt.entry(userID)
+	p.stepsCompleted = completed
+	if total > 0 {
+		p.totalSteps = total
+	}
+	p.lastInteraction = time.Now()
+	pt.dirty = true
+}
+
+// remove 停止跟踪离开的学生
+func (pt *progressTracker) remove(userID string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if _, ok := pt.entries[userID]; ok {
+		delete(pt.entries, userID)
+		pt.dirty = true
+	}
+}
+
+// reset 清空所有学生的进度，保留已跟踪的学生
+func (pt *progressTracker) reset() {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	now := time.Now()
+	for userID := range pt.entries {
+		pt.entries[userID] = &studentProgress{lastInteraction: now}
+	}
+	pt.dirty = true
+}
+
+// rows 生成看板数据，并返回自上次提交以来是否有变化
+// commit 为 true 时清除变化标记，用于周期推送
+func (pt *progressTracker) rows(idleThreshold time.Duration, commit bool) ([]dashboardRow, bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	now := time.Now()
+	changed := pt.dirty
+	rows := make([]dashboardRow, 0, len(pt.entries))
+	for userID, p := range pt.entries {
+		idle := idleThreshold > 0 && now.Sub(p.lastInteraction) > idleThreshold
+		if idle != p.idle {
+			changed = true
+			if commit {
+				p.idle = idle
+			}
+		}
+		rows = append(rows, dashboardRow{
+			DeviceCode:      userID,
+			StepsCompleted:  p.stepsCompleted,
+			TotalSteps:      p.totalSteps,
+			Errors:          p.errors,
+			Manipulations:   p.manipulations,
+			LastInteraction: p.lastInteraction.UnixMilli(),
+			Idle:            idle,
+		})
+	}
+	if commit {
+		pt.dirty = false
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].DeviceCode < rows[j].DeviceCode
+	})
+	return rows, changed
+}
+
+// flushDashboard 在 tick 中按间隔向教师和观察者推送有变化的进度看板
+func (h *Hub) flushDashboard() {
+	if time.Since(h.progress.lastPush) < dashboardPeriod {
+		return
+	}
+	h.progress.lastPush = time.Now()
+
+	rows, changed := h.progress.rows(h.config.IdleThreshold, true)
+	if !changed {
+		return
+	}
+
+	response := protocol.Message{
+		Type: protocol.DashboardUpdate,
+		Data: rows,
+	}
+	h.sendTo((*Client).isStaff, marshalMessage(response))
+}
+
+// handleProgressReport 处理学生上报的练习进度
+// 课程定义了步骤时步骤进度由服务器跟踪，忽略客户端上报
+func (c *Client) handleProgressReport(data interface{}) {
+	if c.user.Role != models.Student {
+		return
+	}
+
+	var req progressReportRequest
+	if err := decodeData(data, &req); err != nil || req.StepsCompleted < 0 || req.TotalSteps < 0 ||
+		(req.TotalSteps > 0 && req.StepsCompleted > req.TotalSteps) {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	if course, found := c.hub.courses.GetCourse(courseID); found && len(course.Steps) > 0 {
+		return
+	}
+	c.hub.progress.setSteps(c.user.ID, req.StepsCompleted, req.TotalSteps)
+}
+
+// handleDashboardRequest 处理教师请求进度看板
+func (c *Client) handleDashboardRequest() {
+	if !c.requireStaff() {
+		return
+	}
+
+	rows, _ := c.hub.progress.rows(c.hub.config.IdleThreshold, false)
+	c.sendMessage(protocol.Message{
+		Type: protocol.DashboardUpdate,
+		Data: rows,
+	})
+}
@@ -0,0 +1,249 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/internal/script"
+	"xnfz/internal/session"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// 自定义属性限制
+const (
+	maxPropertiesPerMessage = 32 // 单条消息最多修改的属性数
+	maxUndeclaredProperties = 64 // 每个对象最多保存的未在课程中声明的属性数
+	maxPropertyNameLength   = 64 // 未声明属性名的最大长度（字节）
+)
+
+// PropertyValue 带类型的对象属性值
+type PropertyValue struct {
+	Type  models.PropertyType `json:"type"`
+	Value interface{}         `json:"value"`
+}
+
+// UpdateProperties 修改对象属性，replace 为 true 时先清除对象的其他属性，返回实际被删除的属性名
+// 修改后对象未声明（declared 返回 false）的属性超过 maxUndeclaredProperties 时不做修改并返回 false
+func (cd *CourseDetail) UpdateProperties(objectID int32, values map[string]PropertyValue, remove []string, replace bool, declared func(string) bool) ([]string, bool) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	properties := cd.Properties[objectID]
+	if undeclaredAfter(properties, values, remove, replace, declared) > maxUndeclaredProperties {
+		return nil, false
+	}
+	if properties == nil {
+		properties = make(map[string]PropertyValue, len(values))
+		cd.Properties[objectID] = properties
+	}
+	if replace {
+		remove = remove[:0]
+		for name := range properties {
+			if _, ok := values[name]; !ok {
+				remove = append(remove, name)
+			}
+		}
+	}
+
+	removed := make([]string, 0, len(remove))
+	for _, name := range remove {
+		if _, ok := properties[name]; ok {
+			delete(properties, name)
+			removed = append(removed, name)
+		}
+	}
+	for name, value := range values {
+		properties[name] = value
+	}
+	if len(properties) == 0 {
+		delete(cd.Properties, objectID)
+	}
+	sort.Strings(removed)
+	return removed, true
+}
+
+// undeclaredAfter 返回修改后对象未声明属性的个数
+func undeclaredAfter(properties map[string]PropertyValue, values map[string]PropertyValue, remove []string, replace bool, declared func(string) bool) int {
+	removing := make(map[string]bool, len(remove))
+	for _, name := range remove {
+		removing[name] = true
+	}
+
+	count := 0
+	for name := range properties {
+		if _, updated := values[name]; updated || replace || removing[name] {
+			continue
+		}
+		if !declared(name) {
+			count++
+		}
+	}
+	for name := range values {
+		if !declared(name) {
+			count++
+		}
+	}
+	return count
+}
+
+// propertyRequest 设置（替换全部）或修改（部分更新）对象属性请求
+// 课程中声明过的属性可以省略类型，未声明的属性必须给出类型
+type propertyRequest struct {
+	ObjectID   int32                    `json:"objectId"`
+	Properties map[string]PropertyValue `json:"properties"`
+	Remove     []string                 `json:"remove"`
+}
+
+// propertyChangeMessage 属性变化推送
+type propertyChangeMessage struct {
+	ObjectID   int32                    `json:"objectId"`
+	Properties map[string]PropertyValue `json:"properties"`
+	Removed    []string                 `json:"removed,omitempty"`
+	Replace    bool                     `json:"replace,omitempty"`
+}
+
+// normalizeProperties 按课程声明校验属性类型和取值
+func (c *Client) normalizeProperties(course *models.Course, scene string, req propertyRequest) (map[string]PropertyValue, e.ErrorMessage, bool) {
+	manifest, ok := c.hub.objectAllowed(course, scene, req.ObjectID)
+	if !ok {
+		return nil, e.ErrObjectNotAllowed, false
+	}
+	for _, name := range req.Remove {
+		if !manifest.Allows(name) {
+			return nil, e.ErrObjectNotAllowed, false
+		}
+	}
+
+	result := make(map[string]PropertyValue, len(req.Properties))
+	for name, input := range req.Properties {
+		if !manifest.Allows(name) {
+			return nil, e.ErrObjectNotAllowed, false
+		}
+		spec, declared := course.PropertySpec(req.ObjectID, name)
+		if !declared {
+			// 枚举属性必须在课程中声明可选值
+			if !input.Type.Valid() || input.Type == models.PropertyEnum {
+				return nil, e.ErrInvalidData, false
+			}
+			if name == "" || len(name) > maxPropertyNameLength {
+				return nil, e.ErrInvalidData, false
+			}
+			spec = models.PropertySpec{ObjectID: req.ObjectID, Name: name, Type: input.Type}
+		} else if input.Type != "" && input.Type != spec.Type {
+			return nil, e.ErrInvalidData, false
+		}
+
+		value, err := spec.Normalize(input.Value)
+		if err != nil {
+			c.hub.logger.Warn("Invalid property value",
+				zap.String("deviceCode", c.user.ID),
+				zap.Int32("objectID", req.ObjectID),
+				zap.Error(err))
+			return nil, e.ErrInvalidData, false
+		}
+		result[name] = PropertyValue{Type: spec.Type, Value: value}
+	}
+	return result, e.ErrorMessage{}, true
+}
+
+// handleObjectProperties 处理设置或修改对象属性消息
+func (c *Client) handleObjectProperties(msgType int32, data interface{}) {
+	if c.hub.classroom.isFrozen(c) {
+		c.sendErrorResponse(e.ErrInteractionFrozen)
+		return
+	}
+	if !c.requireAssets() {
+		return
+	}
+
+	var req propertyRequest
+	if err := decodeData(data, &req); err != nil || req.ObjectID == 0 ||
+		len(req.Properties)+len(req.Remove) > maxPropertiesPerMessage {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	course, found := c.hub.courses.GetCourse(courseID)
+	if !found {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+
+	scene := c.hub.sceneOf(c)
+	values, reason, ok := c.normalizeProperties(course, scene, req)
+	if !ok {
+		if isMistake(reason) {
+			c.recordMistake()
+		}
+		c.sendErrorResponse(reason)
+		return
+	}
+
+	replace := msgType == protocol.PropertySet
+	if replace {
+		req.Remove = nil
+	}
+	removed, ok := c.hub.sceneDetail(scene).UpdateProperties(req.ObjectID, values, req.Remove, replace, func(name string) bool {
+		_, declared := course.PropertySpec(req.ObjectID, name)
+		return declared
+	})
+	if !ok {
+		c.hub.logger.Warn("Too many undeclared properties",
+			zap.String("deviceCode", c.user.ID),
+			zap.Int32("objectID", req.ObjectID))
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	c.hub.sceneChanged(scene, c.user.ID, "property")
+
+	c.hub.broadcastScene(scene, protocol.Message{
+		Type:       msgType,
+		DeviceCode: c.user.ID,
+		Data: propertyChangeMessage{
+			ObjectID:   req.ObjectID,
+			Properties: values,
+			Removed:    removed,
+			Replace:    replace,
+		},
+	})
+
+	c.propertiesChanged(req.ObjectID, values, removed)
+}
+
+// propertiesChanged 将每个属性的变化交给会话记录、评分和课程脚本
+func (c *Client) propertiesChanged(objectID int32, values map[string]PropertyValue, removed []string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var s *session.Session
+	if c.user.Role == models.Student {
+		c.hub.progress.interact(c.user.ID)
+		s = c.ensureSession()
+	}
+
+	record := func(change session.PropertyChange) {
+		if s != nil && s.RecordProperty(change) {
+			c.hub.logger.Warn("Session property recording truncated",
+				zap.String("deviceCode", c.user.ID),
+				zap.String("sessionID", s.ID))
+		}
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		record(session.PropertyChange{ObjectID: objectID, Name: name, Value: values[name].Value, Time: now})
+		c.hub.invokeScript(script.HookProperty, c.user.ID, int(objectID), name, values[name].Value)
+	}
+	for _, name := range removed {
+		record(session.PropertyChange{ObjectID: objectID, Name: name, Time: now})
+		c.hub.invokeScript(script.HookProperty, c.user.ID, int(objectID), name, nil)
+	}
+}
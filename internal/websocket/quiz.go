@@ -0,0 +1,357 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/internal/session"
+	"xnfz/pkg/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// quiz 一道课堂测验或投票，correct 为空表示投票（没有标准答案）
+type quiz struct {
+	id       string
+	question string
+	options  []string
+	multiple bool
+	correct  []int
+	deadline time.Time        // 零值表示没有截止时间
+	answers  map[string][]int // 学生 deviceCode -> 选择的选项下标
+	closed   bool
+}
+
+// open 判断测验是否仍可作答
+func (q *quiz) open(now time.Time) bool {
+	return !q.closed && (q.deadline.IsZero() || now.Before(q.deadline))
+}
+
+// isCorrect 判断选择是否与标准答案一致，投票返回 nil
+func (q *quiz) isCorrect(choices []int) *bool {
+	if len(q.correct) == 0 {
+		return nil
+	}
+	correct := len(choices) == len(q.correct)
+	for i := 0; correct && i < len(choices); i++ {
+		correct = choices[i] == q.correct[i]
+	}
+	return &correct
+}
+
+// quizRegistry 当前课程中发布的测验
+type quizRegistry struct {
+	quizzes map[string]*quiz
+	mu      sync.Mutex
+}
+
+func newQuizRegistry() *quizRegistry {
+	return &quizRegistry{quizzes: make(map[string]*quiz)}
+}
+
+// reset 清空所有测验
+func (r *quizRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quizzes = make(map[string]*quiz)
+}
+
+// quizStartRequest 发布测验请求
+type quizStartRequest struct {
+	Question        string   `json:"question"`
+	Options         []string `json:"options"`
+	Multiple        bool     `json:"multiple"`
+	Correct         []int    `json:"correct"`         // 标准答案，为空表示投票
+	DurationSeconds int      `json:"durationSeconds"` // 作答时长，0 表示由教师手动结束
+}
+
+// quizAnswerRequest 学生作答请求
+type quizAnswerRequest struct {
+	QuizID  string `json:"quizId"`
+	Choices []int  `json:"choices"`
+}
+
+// quizCloseRequest 结束测验请求
+type quizCloseRequest struct {
+	QuizID string `json:"quizId"`
+}
+
+// quizView 发给学生的测验内容，结束前不包含标准答案
+type quizView struct {
+	QuizID   string   `json:"quizId"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Multiple bool     `json:"multiple"`
+	Deadline int64    `json:"deadline,omitempty"` // Unix 毫秒
+	Correct  []int    `json:"correct,omitempty"`
+}
+
+// quizResults 测验统计
+type quizResults struct {
+	quizView
+	Counts   []int `json:"counts"`   // 每个选项被选择的次数
+	Answered int   `json:"answered"` // 已作答人数
+	Total    int   `json:"total"`    // 在线学生人数
+	Right    int   `json:"right"`    // 答对人数，投票时为 0
+	Closed   bool  `json:"closed"`
+}
+
+// view 返回测验内容，调用方需持有锁
+func (q *quiz) view(withAnswer bool) quizView {
+	v := quizView{
+		QuizID:   q.id,
+		Question: q.question,
+		Options:  q.options,
+		Multiple: q.multiple,
+	}
+	if !q.deadline.IsZero() {
+		v.Deadline = q.deadline.UnixMilli()
+	}
+	if withAnswer {
+		v.Correct = q.correct
+	}
+	return v
+}
+
+// results 汇总作答情况，调用方需持有锁
+func (q *quiz) results(total int) quizResults {
+	results := quizResults{
+		quizView: q.view(true),
+		Counts:   make([]int, len(q.options)),
+		Answered: len(q.answers),
+		Total:    total,
+		Closed:   q.closed,
+	}
+	for _, choices := range q.answers {
+		for _, choice := range choices {
+			results.Counts[choice]++
+		}
+		if correct := q.isCorrect(choices); correct != nil && *correct {
+			results.Right++
+		}
+	}
+	return results
+}
+
+// normalizeChoices 校验并排序选项下标，去除重复
+func normalizeChoices(choices []int, options int, multiple bool) ([]int, bool) {
+	seen := make(map[int]bool, len(choices))
+	result := make([]int, 0, len(choices))
+	for _, choice := range choices {
+		if choice < 0 || choice >= options {
+			return nil, false
+		}
+		if !seen[choice] {
+			seen[choice] = true
+			result = append(result, choice)
+		}
+	}
+	if len(result) == 0 || (!multiple && len(result) > 1) {
+		return nil, false
+	}
+	sort.Ints(result)
+	return result, true
+}
+
+// publishQuizResults 向教师和观察者推送测验统计
+func (h *Hub) publishQuizResults(results quizResults) {
+	response := protocol.Message{
+		Type: protocol.QuizResults,
+		Data: results,
+	}
+	h.sendTo((*Client).isStaff, marshalMessage(response))
+}
+
+// closeQuiz 结束测验并向所有客户端推送最终结果（含标准答案），测验已结束时返回 false
+func (h *Hub) closeQuiz(q *quiz) bool {
+	total := len(h.clientsWhere(isStudent))
+
+	h.quizzes.mu.Lock()
+	if q.closed {
+		h.quizzes.mu.Unlock()
+		return false
+	}
+	q.closed = true
+	results := q.results(total)
+	h.quizzes.mu.Unlock()
+
+	h.logger.Info("Quiz closed",
+		zap.String("quizID", q.id),
+		zap.Int("answered", results.Answered),
+		zap.Int("total", total))
+
+	response := protocol.Message{
+		Type: protocol.QuizClose,
+		Data: results,
+	}
+	h.sendTo(allClients, marshalMessage(response))
+	return true
+}
+
+// flushQuizzes 结束已到截止时间的测验
+func (h *Hub) flushQuizzes() {
+	now := time.Now()
+	var expired []*quiz
+
+	h.quizzes.mu.Lock()
+	for _, q := range h.quizzes.quizzes {
+		if !q.closed && !q.open(now) {
+			expired = append(expired, q)
+		}
+	}
+	h.quizzes.mu.Unlock()
+
+	for _, q := range expired {
+		h.closeQuiz(q)
+	}
+}
+
+// openQuizzes 返回仍可作答的测验，用于向新加入的客户端补发
+func (h *Hub) openQuizzes() []quizView {
+	now := time.Now()
+
+	h.quizzes.mu.Lock()
+	defer h.quizzes.mu.Unlock()
+
+	var views []quizView
+	for _, q := range h.quizzes.quizzes {
+		if q.open(now) {
+			views = append(views, q.view(false))
+		}
+	}
+	return views
+}
+
+// handleQuizStart 处理教师发布测验或投票消息
+func (c *Client) handleQuizStart(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req quizStartRequest
+	if err := decodeData(data, &req); err != nil || req.Question == "" || len(req.Options) < 2 || req.DurationSeconds < 0 {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	var correct []int
+	if len(req.Correct) > 0 {
+		var ok bool
+		if correct, ok = normalizeChoices(req.Correct, len(req.Options), req.Multiple); !ok {
+			c.sendErrorResponse(e.ErrInvalidData)
+			return
+		}
+	}
+
+	q := &quiz{
+		id:       uuid.New().String(),
+		question: req.Question,
+		options:  req.Options,
+		multiple: req.Multiple,
+		correct:  correct,
+		answers:  make(map[string][]int),
+	}
+	if req.DurationSeconds > 0 {
+		q.deadline = time.Now().Add(time.Duration(req.DurationSeconds) * time.Second)
+	}
+
+	c.hub.quizzes.mu.Lock()
+	c.hub.quizzes.quizzes[q.id] = q
+	view := q.view(false)
+	results := q.results(len(c.hub.clientsWhere(isStudent)))
+	c.hub.quizzes.mu.Unlock()
+
+	c.hub.logger.Info("Quiz started",
+		zap.String("teacher", c.user.ID),
+		zap.String("quizID", q.id),
+		zap.Int("options", len(q.options)),
+		zap.Int("durationSeconds", req.DurationSeconds))
+
+	response := protocol.Message{
+		Type:       protocol.QuizStart,
+		DeviceCode: c.user.ID,
+		Data:       view,
+	}
+	c.hub.sendTo(allClients, marshalMessage(response))
+	c.hub.publishQuizResults(results)
+}
+
+// handleQuizAnswer 处理学生作答消息，截止前可以修改答案
+func (c *Client) handleQuizAnswer(data interface{}) {
+	if c.user.Role != models.Student {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return
+	}
+
+	var req quizAnswerRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	now := time.Now()
+	total := len(c.hub.clientsWhere(isStudent))
+
+	c.hub.quizzes.mu.Lock()
+	q, ok := c.hub.quizzes.quizzes[req.QuizID]
+	if !ok {
+		c.hub.quizzes.mu.Unlock()
+		c.sendErrorResponse(e.ErrQuizNotFound)
+		return
+	}
+	if !q.open(now) {
+		c.hub.quizzes.mu.Unlock()
+		c.sendErrorResponse(e.ErrQuizClosed)
+		return
+	}
+	choices, valid := normalizeChoices(req.Choices, len(q.options), q.multiple)
+	if !valid {
+		c.hub.quizzes.mu.Unlock()
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	q.answers[c.user.ID] = choices
+	answer := session.QuizAnswer{
+		QuizID:     q.id,
+		Question:   q.question,
+		Choices:    choices,
+		Correct:    q.isCorrect(choices),
+		AnsweredAt: now,
+	}
+	results := q.results(total)
+	c.hub.quizzes.mu.Unlock()
+
+	if s := c.ensureSession(); s != nil {
+		s.AnswerQuiz(answer)
+	}
+	c.hub.publishQuizResults(results)
+}
+
+// handleQuizClose 处理教师结束测验消息
+func (c *Client) handleQuizClose(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req quizCloseRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	c.hub.quizzes.mu.Lock()
+	q, ok := c.hub.quizzes.quizzes[req.QuizID]
+	c.hub.quizzes.mu.Unlock()
+
+	if !ok {
+		c.sendErrorResponse(e.ErrQuizNotFound)
+		return
+	}
+	if !c.hub.closeQuiz(q) {
+		c.sendErrorResponse(e.ErrQuizClosed)
+	}
+}
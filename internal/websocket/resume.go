@@ -0,0 +1,243 @@
+package websocket
+
+import (
+	"encoding/json"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/internal/session"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// load 用保存的 JSON 状态替换场景状态
+func (cd *CourseDetail) load(raw json.RawMessage) error {
+	var saved struct {
+		Data       map[int32]interface{}              `json:"data"`
+		Objects    map[int32]*SceneObject             `json:"objects"`
+		Properties map[int32]map[string]PropertyValue `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return err
+	}
+
+	state := sceneState{
+		data:       saved.Data,
+		objects:    make(map[int32]SceneObject, len(saved.Objects)),
+		properties: saved.Properties,
+	}
+	for id, object := range saved.Objects {
+		if object != nil {
+			state.objects[id] = *object
+		}
+	}
+	cd.restore(state)
+	return nil
+}
+
+// reserveNetworkIDs 恢复的场景中包含运行时对象时，保证之后分配的网络 ID 不与其重复
+func (h *Hub) reserveNetworkIDs(cd *CourseDetail) {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+
+	for id := range cd.Objects {
+		for {
+			current := h.networkIDs.Load()
+			if id-networkIDBase <= current || h.networkIDs.CompareAndSwap(current, id-networkIDBase) {
+				break
+			}
+		}
+	}
+}
+
+// practicing 判断当前是否处于练习模式
+func (h *Hub) practicing() bool {
+	_, mode := h.courseDetail.Course()
+	return models.CourseMode(mode) == models.PracticeMode
+}
+
+// saveProgress 练习模式下保存学生的会话进度，已完成全部步骤的学生清除保存的进度
+func (h *Hub) saveProgress(c *Client, s *session.Session) {
+	courseID, _ := h.courseDetail.Course()
+	if c.user.Role != models.Student || !h.practicing() || s.Course.ID != courseID {
+		return
+	}
+	if s.Finished() {
+		h.sessions.ResetProgress(c.user.ID, courseID)
+		return
+	}
+
+	// 分组场景属于整组，只保存个人工作区
+	var workspace json.RawMessage
+	if scene := h.sceneOf(c); scene == workspaceScene(c.user.ID) {
+		raw, err := json.Marshal(h.sceneDetail(scene))
+		if err != nil {
+			h.logger.Error("Failed to marshal workspace", zap.String("deviceCode", c.user.ID), zap.Error(err))
+		}
+		workspace = raw
+	}
+	h.sessions.SaveProgress(s, workspace)
+}
+
+// progressOffer 向学生提供的可恢复进度
+type progressOffer struct {
+	CourseID  string               `json:"courseId"`
+	Total     int                  `json:"total"`
+	Steps     session.StepProgress `json:"steps"`
+	Elapsed   float64              `json:"elapsed"`
+	SavedAt   int64                `json:"savedAt"` // Unix 毫秒
+	Resumed   bool                 `json:"resumed,omitempty"`
+	Discarded bool                 `json:"discarded,omitempty"`
+}
+
+// newProgressOffer 根据保存的进度生成推送内容
+func (h *Hub) newProgressOffer(saved session.SavedProgress) progressOffer {
+	offer := progressOffer{
+		CourseID: saved.CourseID,
+		Steps:    saved.Steps,
+		Elapsed:  saved.Elapsed,
+		SavedAt:  saved.SavedAt.UnixMilli(),
+	}
+	if course, ok := h.courses.GetCourse(saved.CourseID); ok {
+		offer.Total = len(course.Steps)
+	}
+	return offer
+}
+
+// pendingProgress 练习模式下返回学生在当前课程中保存的进度
+func (h *Hub) pendingProgress(c *Client) (progressOffer, bool) {
+	if c.user.Role != models.Student || !h.practicing() {
+		return progressOffer{}, false
+	}
+	courseID, _ := h.courseDetail.Course()
+	saved, ok := h.sessions.Progress(c.user.ID, courseID)
+	if !ok {
+		return progressOffer{}, false
+	}
+	return h.newProgressOffer(saved), true
+}
+
+// offerProgress 学生有当前课程的保存进度时，询问是否继续
+func (h *Hub) offerProgress(c *Client) {
+	if offer, ok := h.pendingProgress(c); ok {
+		c.sendMessage(protocol.Message{
+			Type:       protocol.ProgressOffer,
+			DeviceCode: c.user.ID,
+			Data:       offer,
+		})
+	}
+}
+
+// progressResumeRequest 学生选择继续或放弃保存的进度
+type progressResumeRequest struct {
+	Resume bool `json:"resume"`
+}
+
+// handleProgressResume 处理学生继续保存进度的消息：恢复步骤、用时和个人工作区
+func (c *Client) handleProgressResume(data interface{}) {
+	var req progressResumeRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	saved, ok := c.hub.sessions.Progress(c.user.ID, courseID)
+	if c.user.Role != models.Student || !c.hub.practicing() || !ok {
+		c.sendErrorResponse(e.ErrProgressNotFound)
+		return
+	}
+
+	// 保存的进度在放弃或成功恢复后才删除，恢复失败时学生可以再次尝试
+	offer := c.hub.newProgressOffer(saved)
+	if !req.Resume {
+		c.hub.sessions.ResetProgress(c.user.ID, courseID)
+		offer.Discarded = true
+		c.sendMessage(protocol.Message{Type: protocol.ProgressResume, DeviceCode: c.user.ID, Data: offer})
+		return
+	}
+
+	s := c.ensureSession()
+	if s == nil {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+	s.Resume(saved)
+	c.hub.sessions.ResetProgress(c.user.ID, courseID)
+
+	scene := c.hub.sceneOf(c)
+	if len(saved.Workspace) > 0 && scene == workspaceScene(c.user.ID) {
+		detail := c.hub.sceneDetail(scene)
+		if err := detail.load(saved.Workspace); err != nil {
+			c.hub.logger.Error("Failed to restore workspace", zap.String("deviceCode", c.user.ID), zap.Error(err))
+		}
+		c.hub.reserveNetworkIDs(detail)
+		c.hub.broadcastScene(scene, protocol.Message{
+			Type:       protocol.CourseDetail,
+			DeviceCode: c.user.ID,
+			Data:       detail,
+		})
+	}
+
+	c.hub.logger.Info("Practice progress resumed",
+		zap.String("deviceCode", c.user.ID),
+		zap.String("courseID", courseID),
+		zap.String("step", saved.Steps.Current))
+
+	offer.Resumed = true
+	c.hub.sendTo(func(client *Client) bool {
+		return client.user.ID == c.user.ID || client.isStaff()
+	}, marshalMessage(protocol.Message{Type: protocol.ProgressResume, DeviceCode: c.user.ID, Data: offer}))
+	c.publishStepState(s)
+}
+
+// progressResetRequest 教师清除保存进度请求，deviceCode 为空表示全部学生，courseId 为空表示当前课程
+type progressResetRequest struct {
+	DeviceCode string `json:"deviceCode"`
+	CourseID   string `json:"courseId"`
+}
+
+// progressResetMessage 清除保存进度推送
+type progressResetMessage struct {
+	DeviceCode string `json:"deviceCode,omitempty"`
+	CourseID   string `json:"courseId"`
+	Removed    int    `json:"removed"`
+}
+
+// handleProgressReset 处理教师清除学生保存进度的消息
+func (c *Client) handleProgressReset(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req progressResetRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	if req.CourseID == "" {
+		req.CourseID, _ = c.hub.courseDetail.Course()
+	}
+	if req.CourseID == "" {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+
+	removed := c.hub.sessions.ResetProgress(req.DeviceCode, req.CourseID)
+	c.hub.logger.Info("Practice progress reset",
+		zap.String("teacher", c.user.ID),
+		zap.String("deviceCode", req.DeviceCode),
+		zap.String("courseID", req.CourseID),
+		zap.Int("removed", removed))
+
+	// 被清除的学生收到推送后关闭继续练习的提示
+	response := protocol.Message{
+		Type:       protocol.ProgressReset,
+		DeviceCode: c.user.ID,
+		Data:       progressResetMessage{DeviceCode: req.DeviceCode, CourseID: req.CourseID, Removed: removed},
+	}
+	c.hub.sendTo(func(client *Client) bool {
+		return client.isStaff() || client.user.ID == req.DeviceCode || (req.DeviceCode == "" && isStudent(client))
+	}, marshalMessage(response))
+}
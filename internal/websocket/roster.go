@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"sort"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+)
+
+// 连接质量
+const (
+	QualityUnknown = "unknown"
+	QualityGood    = "good"
+	QualityFair    = "fair"
+	QualityPoor    = "poor"
+)
+
+// Participant 花名册中的参与者信息
+type Participant struct {
+	UserID     string          `json:"userId"`
+	Name       string          `json:"name"`
+	Role       models.UserRole `json:"role"`
+	DeviceCode string          `json:"deviceCode"`
+	Quality    string          `json:"quality"`
+	RTT        int64           `json:"rtt"`
+	SessionID  string          `json:"sessionId"`
+	CourseID   string          `json:"courseId"`
+	AppVersion string          `json:"appVersion,omitempty"`
+	Platform   string          `json:"platform,omitempty"`
+	DeviceName string          `json:"deviceName,omitempty"`
+	Battery    *int            `json:"battery,omitempty"`
+	Charging   bool            `json:"charging,omitempty"`
+}
+
+// connectionQuality 根据时间同步得到的 RTT 和发送队列积压评估连接质量
+func (c *Client) connectionQuality() (string, int64) {
+	_, rtt, ok := c.clock.estimator.Estimate()
+	switch {
+	case len(c.send) > cap(c.send)/2:
+		return QualityPoor, rtt
+	case !ok:
+		return QualityUnknown, 0
+	case rtt < 80:
+		return QualityGood, rtt
+	case rtt < 200:
+		return QualityFair, rtt
+	default:
+		return QualityPoor, rtt
+	}
+}
+
+// participant 返回客户端当前的花名册信息
+func (c *Client) participant() Participant {
+	quality, rtt := c.connectionQuality()
+	p := Participant{
+		UserID:     c.user.ID,
+		Name:       c.user.Name,
+		Role:       c.user.Role,
+		DeviceCode: c.user.ID,
+		Quality:    quality,
+		RTT:        rtt,
+		AppVersion: c.hello.AppVersion,
+		Platform:   c.hello.Platform,
+	}
+	if d, ok := c.hub.devices.Get(c.user.ID); ok {
+		p.DeviceName = d.Name
+		p.Battery = d.Battery
+		p.Charging = d.Charging
+	}
+	if s := c.getSession(); s != nil {
+		p.SessionID = s.ID
+		p.CourseID = s.Course.ID
+	}
+	return p
+}
+
+// isStaff 判断客户端是否为教师或观察者
+func (c *Client) isStaff() bool {
+	return c.user.Role == models.Teacher || c.user.Role == models.Observer
+}
+
+// rosterRecipient 判断客户端是否接收花名册推送
+func (h *Hub) rosterRecipient(c *Client) bool {
+	return h.config.RosterToAll || c.isStaff()
+}
+
+// roster 返回当前所有在线参与者，按用户 ID 排序
+func (h *Hub) roster() []Participant {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	roster := make([]Participant, 0, len(h.clients))
+	for client := range h.clients {
+		roster = append(roster, client.participant())
+	}
+	sort.Slice(roster, func(i, j int) bool {
+		return roster[i].UserID < roster[j].UserID
+	})
+	return roster
+}
+
+// publishPresence 向花名册接收者推送在线状态事件及最新花名册快照
+func (h *Hub) publishPresence(eventType int32, p Participant) {
+	event := protocol.Message{
+		Type:       eventType,
+		DeviceCode: p.DeviceCode,
+		Data:       p,
+	}
+	h.sendTo(h.rosterRecipient, marshalMessage(event))
+
+	snapshot := protocol.Message{
+		Type: protocol.RosterSnapshot,
+		Data: h.roster(),
+	}
+	h.sendTo(h.rosterRecipient, marshalMessage(snapshot))
+}
+
+// handleRosterRequest 处理花名册请求
+func (c *Client) handleRosterRequest() {
+	if !c.hub.rosterRecipient(c) {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return
+	}
+
+	response := protocol.Message{
+		Type: protocol.RosterSnapshot,
+		Data: c.hub.roster(),
+	}
+	c.sendMessage(response)
+}
@@ -0,0 +1,227 @@
+package websocket
+
+import (
+	"sync"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// sharedScene 全班共享的对象状态空间（hub.courseDetail）
+const sharedScene = ""
+
+// workspaceScene 返回练习模式下学生个人工作区的场景标识
+func workspaceScene(userID string) string {
+	return "workspace:" + userID
+}
+
+// sceneRegistry 管理共享场景之外的对象状态空间以及教师的观察、演示关系
+type sceneRegistry struct {
+	details       map[string]*CourseDetail
+	observing     map[string]string // 教师/观察者 deviceCode -> 正在观察的场景
+	demonstrating string            // 正在向全班演示的场景
+	mu            sync.RWMutex
+}
+
+func newSceneRegistry() *sceneRegistry {
+	return &sceneRegistry{
+		details:   make(map[string]*CourseDetail),
+		observing: make(map[string]string),
+	}
+}
+
+// detail 获取场景的课程详情，不存在时创建一个空的
+func (r *sceneRegistry) detail(key string, courseID string, mode int32) *CourseDetail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	detail, ok := r.details[key]
+	if !ok {
+		detail = NewCourseDetail(courseID, mode)
+		r.details[key] = detail
+	}
+	return detail
+}
+
+// reset 清空所有独立场景及观察、演示关系
+func (r *sceneRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.details = make(map[string]*CourseDetail)
+	r.observing = make(map[string]string)
+	r.demonstrating = sharedScene
+}
+
+// remove 删除不再使用的场景，正在观察或演示这些场景的教师回到共享场景
+func (r *sceneRegistry) remove(keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		delete(r.details, key)
+		for staffID, observed := range r.observing {
+			if observed == key {
+				delete(r.observing, staffID)
+			}
+		}
+		if r.demonstrating == key {
+			r.demonstrating = sharedScene
+		}
+	}
+}
+
+// observe 设置教师正在观察的场景，key 为空表示停止观察
+func (r *sceneRegistry) observe(staffID string, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key == sharedScene {
+		delete(r.observing, staffID)
+		return
+	}
+	r.observing[staffID] = key
+}
+
+// demonstrate 设置正在向全班演示的场景，key 为空表示停止演示
+func (r *sceneRegistry) demonstrate(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.demonstrating = key
+}
+
+// watching 判断用户是否通过观察或演示接收指定场景的消息
+func (r *sceneRegistry) watching(userID string, key string) bool {
+	if key == sharedScene {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.observing[userID] == key || r.demonstrating == key
+}
+
+// sceneOf 返回客户端所在的对象状态空间
+// 分组中的学生使用分组场景，练习模式下的其他学生使用个人工作区
+func (h *Hub) sceneOf(c *Client) string {
+	if c.user.Role != models.Student {
+		return sharedScene
+	}
+	if groupID, ok := h.groups.groupOf(c.user.ID); ok {
+		return groupScene(groupID)
+	}
+	if _, mode := h.courseDetail.Course(); models.CourseMode(mode) == models.PracticeMode {
+		return workspaceScene(c.user.ID)
+	}
+	return sharedScene
+}
+
+// sceneDetail 返回场景对应的课程详情
+func (h *Hub) sceneDetail(key string) *CourseDetail {
+	if key == sharedScene {
+		return h.courseDetail
+	}
+	courseID, mode := h.courseDetail.Course()
+	return h.scenes.detail(key, courseID, mode)
+}
+
+// inScene 判断客户端是否接收指定场景的消息
+func (h *Hub) inScene(c *Client, key string) bool {
+	return h.sceneOf(c) == key || h.scenes.watching(c.user.ID, key)
+}
+
+// broadcastScene 向场景内的客户端及其观察者发送消息
+func (h *Hub) broadcastScene(key string, msg protocol.Message) {
+	h.sendTo(func(c *Client) bool {
+		return h.inScene(c, key)
+	}, marshalMessage(msg))
+}
+
+// workspaceRequest 观察或演示学生工作区（或分组场景）请求，均为空表示停止
+type workspaceRequest struct {
+	DeviceCode string `json:"deviceCode"`
+	GroupID    string `json:"groupId"`
+}
+
+// scene 返回请求对应的场景
+func (r workspaceRequest) scene() string {
+	switch {
+	case r.GroupID != "":
+		return groupScene(r.GroupID)
+	case r.DeviceCode != "":
+		return workspaceScene(r.DeviceCode)
+	}
+	return sharedScene
+}
+
+// workspaceSnapshot 学生工作区（或分组场景）快照
+type workspaceSnapshot struct {
+	DeviceCode string        `json:"deviceCode"`
+	GroupID    string        `json:"groupId,omitempty"`
+	Detail     *CourseDetail `json:"detail,omitempty"`
+}
+
+// handleWorkspaceObserve 处理教师观察学生工作区消息
+func (c *Client) handleWorkspaceObserve(data interface{}) {
+	if !c.requireStaff() {
+		return
+	}
+
+	var req workspaceRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	c.observe(req.scene(), workspaceSnapshot{DeviceCode: req.DeviceCode, GroupID: req.GroupID})
+}
+
+// observe 开始观察场景，并向教师发送场景快照
+func (c *Client) observe(key string, snapshot workspaceSnapshot) {
+	c.hub.scenes.observe(c.user.ID, key)
+
+	if key != sharedScene {
+		snapshot.Detail = c.hub.sceneDetail(key)
+	}
+	c.sendMessage(protocol.Message{
+		Type:       protocol.WorkspaceSnapshot,
+		DeviceCode: snapshot.DeviceCode,
+		Data:       snapshot,
+	})
+}
+
+// handleWorkspaceDemonstrate 处理教师将学生工作区推送给全班演示的消息
+func (c *Client) handleWorkspaceDemonstrate(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req workspaceRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	key := req.scene()
+	c.hub.scenes.demonstrate(key)
+
+	snapshot := workspaceSnapshot{DeviceCode: req.DeviceCode, GroupID: req.GroupID}
+	if key != sharedScene {
+		snapshot.Detail = c.hub.sceneDetail(key)
+	}
+
+	c.hub.logger.Info("Workspace demonstration changed",
+		zap.String("teacher", c.user.ID),
+		zap.String("deviceCode", req.DeviceCode),
+		zap.String("groupID", req.GroupID))
+
+	response := protocol.Message{
+		Type:       protocol.WorkspaceDemonstrate,
+		DeviceCode: req.DeviceCode,
+		Data:       snapshot,
+	}
+	c.hub.sendTo(allClients, marshalMessage(response))
+}
@@ -0,0 +1,318 @@
+package websocket
+
+import (
+	"sort"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// networkIDBase 运行时生成对象的网络 ID 起点，与课程预置对象的 ID 区分
+const networkIDBase = int32(1) << 30
+
+// maxTransformLength 对象变换最多包含位置、旋转四元数和缩放
+const maxTransformLength = 10
+
+// maxSceneObjects 每个场景最多同时存在的运行时对象数
+// 授课模式下每次生成都会把整个场景复制进撤销历史，需要限制场景大小
+const maxSceneObjects = 256
+
+// SceneObject 运行时生成的场景对象
+type SceneObject struct {
+	ID        int32     `json:"id"`
+	PrefabID  string    `json:"prefabId"`
+	ParentID  int32     `json:"parentId,omitempty"` // 0 表示位于场景根节点，也可以是课程预置对象
+	Owner     string    `json:"owner"`
+	Transform []float64 `json:"transform,omitempty"`
+}
+
+// parentExistsLocked 判断父节点是否有效：preset 为 true 表示 parentID 是根节点或课程预置对象，否则必须是场景中的运行时对象
+// 调用方需持有锁
+func (cd *CourseDetail) parentExistsLocked(parentID int32, preset bool) bool {
+	if preset {
+		return true
+	}
+	_, ok := cd.Objects[parentID]
+	return ok
+}
+
+// Spawn 将新对象加入场景图，父节点不存在或场景对象已达上限时返回错误
+func (cd *CourseDetail) Spawn(object *SceneObject, presetParent bool) (e.ErrorMessage, bool) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	if !cd.parentExistsLocked(object.ParentID, presetParent) {
+		return e.ErrInvalidData, false
+	}
+	if len(cd.Objects) >= maxSceneObjects {
+		return e.ErrTooManyObjects, false
+	}
+	cd.Objects[object.ID] = object
+	return e.ErrorMessage{}, true
+}
+
+// Object 返回运行时对象的副本
+func (cd *CourseDetail) Object(objectID int32) (SceneObject, bool) {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+
+	object, ok := cd.Objects[objectID]
+	if !ok {
+		return SceneObject{}, false
+	}
+	return *object, true
+}
+
+// Destroy 删除对象及其所有子对象，并清除它们的操作数据，返回被删除的对象 ID
+func (cd *CourseDetail) Destroy(objectID int32) []int32 {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	if _, ok := cd.Objects[objectID]; !ok {
+		return nil
+	}
+
+	removed := []int32{objectID}
+	for i := 0; i < len(removed); i++ {
+		for id, object := range cd.Objects {
+			if object.ParentID == removed[i] {
+				removed = append(removed, id)
+			}
+		}
+	}
+	for _, id := range removed {
+		delete(cd.Objects, id)
+		delete(cd.Data, id)
+		delete(cd.Properties, id)
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+	return removed
+}
+
+// Reparent 修改对象的父节点，父节点不存在或会形成环时返回 false
+func (cd *CourseDetail) Reparent(objectID int32, parentID int32, presetParent bool) bool {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	object, ok := cd.Objects[objectID]
+	if !ok || !cd.parentExistsLocked(parentID, presetParent) {
+		return false
+	}
+	for ancestor := parentID; ancestor != 0; {
+		if ancestor == objectID {
+			return false
+		}
+		parent, ok := cd.Objects[ancestor]
+		if !ok {
+			break
+		}
+		ancestor = parent.ParentID
+	}
+	object.ParentID = parentID
+	return true
+}
+
+// objectSpawnRequest 生成对象请求
+type objectSpawnRequest struct {
+	PrefabID  string    `json:"prefabId"`
+	ParentID  int32     `json:"parentId"`
+	Owner     string    `json:"owner"` // 仅教师可以指定，默认为发送者
+	Transform []float64 `json:"transform"`
+}
+
+// objectTargetRequest 删除或修改父节点请求
+type objectTargetRequest struct {
+	ObjectID int32 `json:"objectId"`
+	ParentID int32 `json:"parentId"`
+}
+
+// objectDestroyedMessage 对象删除推送
+type objectDestroyedMessage struct {
+	ObjectID  int32   `json:"objectId"`
+	Destroyed []int32 `json:"destroyed"` // 包括被级联删除的子对象
+}
+
+// objectReparentedMessage 父节点变化推送
+type objectReparentedMessage struct {
+	ObjectID int32 `json:"objectId"`
+	ParentID int32 `json:"parentId"`
+}
+
+// nextNetworkID 分配一个全局唯一的网络 ID
+func (h *Hub) nextNetworkID() int32 {
+	return networkIDBase + h.networkIDs.Add(1)
+}
+
+// presetParent 判断 parentID 是否为场景根节点或课程预置对象
+// 课程声明了对象清单时预置对象以清单为准，否则运行时 ID 段以下的对象都视为预置对象
+func presetParent(course *models.Course, parentID int32) bool {
+	if parentID == 0 {
+		return true
+	}
+	if _, ok := course.ObjectManifest(parentID); ok {
+		return true
+	}
+	return len(course.Objects) == 0 && parentID > 0 && parentID < networkIDBase
+}
+
+// sceneObjectFor 查找客户端所在场景中的对象，并校验客户端是否可以修改它（所有者或教师）
+func (c *Client) sceneObjectFor(objectID int32) (string, SceneObject, bool) {
+	scene := c.hub.sceneOf(c)
+	object, ok := c.hub.sceneDetail(scene).Object(objectID)
+	if !ok {
+		c.sendErrorResponse(e.ErrObjectNotFound)
+		return "", SceneObject{}, false
+	}
+	if object.Owner != c.user.ID && c.user.Role != models.Teacher {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return "", SceneObject{}, false
+	}
+	return scene, object, true
+}
+
+// handleObjectSpawn 处理生成对象消息，服务器分配网络 ID 后推送给场景内的客户端
+func (c *Client) handleObjectSpawn(data interface{}) {
+	if c.hub.classroom.isFrozen(c) {
+		c.sendErrorResponse(e.ErrInteractionFrozen)
+		return
+	}
+	if !c.requireAssets() {
+		return
+	}
+
+	var req objectSpawnRequest
+	if err := decodeData(data, &req); err != nil || req.PrefabID == "" ||
+		len(req.Transform) > maxTransformLength || !finite(req.Transform) {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	course, found := c.hub.courses.GetCourse(courseID)
+	if !found {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+	if !course.HasPrefab(req.PrefabID) {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	owner := c.user.ID
+	if req.Owner != "" && c.user.Role == models.Teacher {
+		owner = req.Owner
+	}
+
+	scene := c.hub.sceneOf(c)
+	object := SceneObject{
+		ID:        c.hub.nextNetworkID(),
+		PrefabID:  req.PrefabID,
+		ParentID:  req.ParentID,
+		Owner:     owner,
+		Transform: req.Transform,
+	}
+	spawned := object
+	if reason, ok := c.hub.sceneDetail(scene).Spawn(&spawned, presetParent(course, req.ParentID)); !ok {
+		if reason == e.ErrTooManyObjects {
+			c.hub.logger.Warn("Scene object limit reached",
+				zap.String("deviceCode", c.user.ID),
+				zap.String("scene", scene))
+		}
+		c.sendErrorResponse(reason)
+		return
+	}
+	c.hub.sceneChanged(scene, c.user.ID, "spawn")
+
+	c.hub.logger.Info("Object spawned",
+		zap.String("deviceCode", c.user.ID),
+		zap.Int32("objectID", object.ID),
+		zap.String("prefabID", object.PrefabID),
+		zap.String("scene", scene))
+
+	c.hub.broadcastScene(scene, protocol.Message{
+		Type:       protocol.ObjectSpawn,
+		DeviceCode: c.user.ID,
+		Data:       object,
+	})
+}
+
+// handleObjectDestroy 处理删除对象消息，子对象一并删除
+func (c *Client) handleObjectDestroy(data interface{}) {
+	if c.hub.classroom.isFrozen(c) {
+		c.sendErrorResponse(e.ErrInteractionFrozen)
+		return
+	}
+	if !c.requireAssets() {
+		return
+	}
+
+	var req objectTargetRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	scene, object, ok := c.sceneObjectFor(req.ObjectID)
+	if !ok {
+		return
+	}
+
+	destroyed := c.hub.sceneDetail(scene).Destroy(object.ID)
+	if len(destroyed) == 0 {
+		c.sendErrorResponse(e.ErrObjectNotFound)
+		return
+	}
+	c.hub.sceneChanged(scene, c.user.ID, "destroy")
+
+	c.hub.logger.Info("Object destroyed",
+		zap.String("deviceCode", c.user.ID),
+		zap.Int32("objectID", object.ID),
+		zap.Int("count", len(destroyed)))
+
+	c.hub.broadcastScene(scene, protocol.Message{
+		Type:       protocol.ObjectDestroy,
+		DeviceCode: c.user.ID,
+		Data:       objectDestroyedMessage{ObjectID: object.ID, Destroyed: destroyed},
+	})
+}
+
+// handleObjectReparent 处理修改对象父节点消息
+func (c *Client) handleObjectReparent(data interface{}) {
+	if c.hub.classroom.isFrozen(c) {
+		c.sendErrorResponse(e.ErrInteractionFrozen)
+		return
+	}
+	if !c.requireAssets() {
+		return
+	}
+
+	var req objectTargetRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	scene, object, ok := c.sceneObjectFor(req.ObjectID)
+	if !ok {
+		return
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	course, found := c.hub.courses.GetCourse(courseID)
+	if !found {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+	if !c.hub.sceneDetail(scene).Reparent(object.ID, req.ParentID, presetParent(course, req.ParentID)) {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	c.hub.sceneChanged(scene, c.user.ID, "reparent")
+
+	c.hub.broadcastScene(scene, protocol.Message{
+		Type:       protocol.ObjectReparent,
+		DeviceCode: c.user.ID,
+		Data:       objectReparentedMessage{ObjectID: object.ID, ParentID: req.ParentID},
+	})
+}
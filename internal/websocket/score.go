@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"xnfz/api"
+	"xnfz/internal/scoring"
+	"xnfz/internal/session"
+	"xnfz/pkg/models"
+)
+
+// scoreReport 练习评分结果推送
+type scoreReport struct {
+	DeviceCode string          `json:"deviceCode"`
+	SessionID  string          `json:"sessionId"`
+	CourseID   string          `json:"courseId"`
+	Score      *scoring.Result `json:"score"`
+}
+
+// recordManipulation 将学生的对象操作记入会话评分，并检查步骤完成条件
+func (c *Client) recordManipulation(data map[int32]interface{}) {
+	if c.user.Role != models.Student {
+		return
+	}
+	s := c.ensureSession()
+	if s == nil {
+		return
+	}
+
+	for objectID := range data {
+		s.Track(scoring.Event{Kind: scoring.EventManipulate, ObjectID: objectID})
+	}
+	c.respondFaults(s, data)
+	c.checkManipulationCriteria(s, data)
+}
+
+// finishSessions 课程结束时结束所有参与者的会话，并推送学生的评分结果
+func (h *Hub) finishSessions() {
+	for _, client := range h.clientsWhere(allClients) {
+		s := client.getSession()
+		if s == nil {
+			continue
+		}
+		h.saveProgress(client, s)
+		h.sessions.EndSession(s.ID)
+		client.setSession(nil)
+		if client.user.Role == models.Student {
+			h.publishScore(client, s)
+		}
+	}
+}
+
+// publishScore 向参与者本人及教师推送评分结果
+func (h *Hub) publishScore(c *Client, s *session.Session) {
+	record := s.Record()
+	if record.Score == nil {
+		return
+	}
+
+	response := protocol.Message{
+		Type:       protocol.ScoreReport,
+		DeviceCode: c.user.ID,
+		Data: scoreReport{
+			DeviceCode: c.user.ID,
+			SessionID:  record.ID,
+			CourseID:   record.CourseID,
+			Score:      record.Score,
+		},
+	}
+	h.sendTo(func(client *Client) bool {
+		return client.user.ID == c.user.ID || client.isStaff()
+	}, marshalMessage(response))
+}
@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/internal/scoring"
+	"xnfz/internal/script"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// courseScript 当前课程的脚本运行时，课程开始时加载，结束或退出时释放
+type courseScript struct {
+	runtime *script.Runtime
+	mu      sync.RWMutex
+}
+
+func (cs *courseScript) get() *script.Runtime {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.runtime
+}
+
+// swap 替换运行时并释放旧的运行时
+func (cs *courseScript) swap(runtime *script.Runtime) {
+	cs.mu.Lock()
+	old := cs.runtime
+	cs.runtime = runtime
+	cs.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+}
+
+// scriptMessage 课程脚本消息（双向）
+type scriptMessage struct {
+	Name string      `json:"name"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// loadScript 为开始的课程加载脚本，课程没有脚本时释放旧的运行时
+func (h *Hub) loadScript(course *models.Course) {
+	if course.Script == "" {
+		h.script.swap(nil)
+		return
+	}
+
+	runtime, err := script.NewRuntime(course.ID, course.Script, scriptHost{hub: h}, h.config.ScriptTimeout, h.logger)
+	if err != nil {
+		h.logger.Error("Failed to load course script", zap.String("courseID", course.ID), zap.Error(err))
+		h.script.swap(nil)
+		return
+	}
+	h.script.swap(runtime)
+	h.logger.Info("Course script loaded", zap.String("courseID", course.ID))
+	runtime.Invoke(script.HookStart)
+}
+
+// unloadScript 课程结束或退出时释放脚本
+func (h *Hub) unloadScript() {
+	h.script.swap(nil)
+}
+
+// invokeScript 调用当前课程脚本的回调，没有脚本时忽略
+// 回调会向客户端发送消息，调用方不能持有 h.mu
+func (h *Hub) invokeScript(hook string, args ...interface{}) {
+	if runtime := h.script.get(); runtime != nil {
+		runtime.Invoke(hook, args...)
+	}
+}
+
+// scriptManipulation 将对象操作按对象 ID 顺序逐个交给课程脚本
+func (h *Hub) scriptManipulation(deviceCode string, data map[int32]interface{}) {
+	if h.script.get() == nil {
+		return
+	}
+
+	objectIDs := make([]int, 0, len(data))
+	for objectID := range data {
+		objectIDs = append(objectIDs, int(objectID))
+	}
+	sort.Ints(objectIDs)
+	for _, objectID := range objectIDs {
+		h.invokeScript(script.HookManipulation, deviceCode, objectID, data[int32(objectID)])
+	}
+}
+
+// handleScriptEvent 处理客户端发给课程脚本的自定义事件
+func (c *Client) handleScriptEvent(data interface{}) {
+	var req scriptMessage
+	if err := decodeData(data, &req); err != nil || req.Name == "" {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	c.hub.invokeScript(script.HookMessage, c.user.ID, req.Name, req.Data)
+}
+
+// scriptHost 课程脚本可调用的服务器能力
+type scriptHost struct {
+	hub *Hub
+}
+
+// Emit 发送脚本消息，target 为空时发给所有客户端
+func (sh scriptHost) Emit(name string, data interface{}, target string) {
+	response := protocol.Message{
+		Type:       protocol.ScriptMessage,
+		DeviceCode: target,
+		Data:       scriptMessage{Name: name, Data: data},
+	}
+	sh.hub.sendTo(func(client *Client) bool {
+		return target == "" || client.user.ID == target
+	}, marshalMessage(response))
+}
+
+// SetObject 修改设备所在场景（deviceCode 为空时为共享场景）的对象状态并广播
+func (sh scriptHost) SetObject(deviceCode string, objectID int32, data interface{}) {
+	scene := sharedScene
+	if deviceCode != "" {
+		clients := sh.hub.clientsByUser(deviceCode)
+		if len(clients) == 0 {
+			return
+		}
+		scene = sh.hub.sceneOf(clients[0])
+	}
+
+	changes := map[int32]interface{}{objectID: data}
+	sh.hub.sceneDetail(scene).Merge(changes)
+	sh.hub.sceneChanged(scene, "script", "script")
+	sh.hub.broadcastScene(scene, protocol.Message{
+		Type: protocol.ObjectManipulation,
+		Data: changes,
+	})
+}
+
+// AddScore 为学生当前会话加减分
+func (sh scriptHost) AddScore(deviceCode string, points int, reason string) {
+	for _, client := range sh.hub.clientsByUser(deviceCode) {
+		if s := client.getSession(); s != nil {
+			s.Track(scoring.Event{Kind: scoring.EventAdjust, Points: points, Detail: reason})
+			return
+		}
+	}
+}
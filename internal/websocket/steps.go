@@ -0,0 +1,253 @@
+package websocket
+
+import (
+	"sync"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/internal/script"
+	"xnfz/internal/session"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// classSteps 教学模式下由教师推进的全班当前步骤
+type classSteps struct {
+	current string
+	mu      sync.RWMutex
+}
+
+func (cs *classSteps) get() string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.current
+}
+
+func (cs *classSteps) set(stepID string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.current = stepID
+}
+
+// stepRequest 步骤开始、完成、跳过或推进请求
+type stepRequest struct {
+	StepID string `json:"stepId"`
+}
+
+// stepStateMessage 参与者步骤进度推送
+type stepStateMessage struct {
+	DeviceCode string `json:"deviceCode"`
+	CourseID   string `json:"courseId"`
+	Total      int    `json:"total"`
+	session.StepProgress
+}
+
+// stepAdvanceMessage 教学模式下全班步骤推进推送
+type stepAdvanceMessage struct {
+	StepID string `json:"stepId"`
+	Title  string `json:"title"`
+}
+
+// teaching 判断当前是否处于教学模式
+func (h *Hub) teaching() bool {
+	_, mode := h.courseDetail.Course()
+	return models.CourseMode(mode) == models.TeachingMode
+}
+
+// initSteps 为新会话设置初始步骤：教学模式跟随全班步骤，否则从第一个步骤开始
+func (h *Hub) initSteps(s *session.Session) {
+	if h.teaching() {
+		s.StartStep(h.steps.get())
+		return
+	}
+	if first, ok := s.Course.NextStep(""); ok {
+		s.StartStep(first.ID)
+	}
+}
+
+// nextStepAfter 返回完成或跳过步骤后的当前步骤，教学模式下等待教师推进
+func (h *Hub) nextStepAfter(course *models.Course, stepID string) string {
+	if h.teaching() {
+		return stepID
+	}
+	next, _ := course.NextStep(stepID)
+	return next.ID
+}
+
+// publishStepState 向参与者本人及教师推送步骤进度，并同步到进度看板
+func (c *Client) publishStepState(s *session.Session) {
+	state := s.StepState()
+	if c.user.Role == models.Student {
+		c.hub.progress.setSteps(c.user.ID, len(state.Completed), len(s.Course.Steps))
+	}
+
+	response := protocol.Message{
+		Type:       protocol.StepState,
+		DeviceCode: c.user.ID,
+		Data: stepStateMessage{
+			DeviceCode:   c.user.ID,
+			CourseID:     s.Course.ID,
+			Total:        len(s.Course.Steps),
+			StepProgress: state,
+		},
+	}
+	c.hub.sendTo(func(client *Client) bool {
+		return client.user.ID == c.user.ID || client.isStaff()
+	}, marshalMessage(response))
+}
+
+// stepFor 解析请求中的步骤，stepId 为空时使用当前步骤
+func (c *Client) stepFor(data interface{}) (*session.Session, models.Step, bool) {
+	var req stepRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return nil, models.Step{}, false
+	}
+
+	s := c.ensureSession()
+	if s == nil {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return nil, models.Step{}, false
+	}
+
+	stepID := req.StepID
+	if stepID == "" {
+		stepID = s.StepState().Current
+	}
+	index := s.Course.StepIndex(stepID)
+	if index < 0 {
+		c.sendErrorResponse(e.ErrStepNotFound)
+		return nil, models.Step{}, false
+	}
+	return s, s.Course.Steps[index], true
+}
+
+// completeStep 将步骤标记为完成并推送进度
+func (c *Client) completeStep(s *session.Session, step models.Step) {
+	if s.CompleteStep(step.ID, c.hub.nextStepAfter(s.Course, step.ID)) {
+		c.hub.logger.Info("Step completed",
+			zap.String("deviceCode", c.user.ID),
+			zap.String("courseID", s.Course.ID),
+			zap.String("stepID", step.ID))
+		c.publishStepState(s)
+		c.hub.invokeScript(script.HookStep, c.user.ID, "complete", step.ID)
+	}
+}
+
+// checkManipulationCriteria 操作了当前步骤要求的对象时自动完成步骤
+func (c *Client) checkManipulationCriteria(s *session.Session, data map[int32]interface{}) {
+	index := s.Course.StepIndex(s.StepState().Current)
+	if index < 0 {
+		return
+	}
+	step := s.Course.Steps[index]
+	if step.Criteria.Type != models.CriteriaManipulate {
+		return
+	}
+	for _, objectID := range step.Criteria.ObjectIDs {
+		if _, ok := data[objectID]; ok {
+			c.completeStep(s, step)
+			return
+		}
+	}
+}
+
+// handleStepStart 处理开始步骤消息，教学模式下学生不能自行切换步骤
+func (c *Client) handleStepStart(data interface{}) {
+	if c.hub.teaching() && c.user.Role == models.Student {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return
+	}
+
+	s, step, ok := c.stepFor(data)
+	if !ok {
+		return
+	}
+	s.StartStep(step.ID)
+	c.publishStepState(s)
+	c.hub.invokeScript(script.HookStep, c.user.ID, "start", step.ID)
+}
+
+// handleStepComplete 处理完成步骤消息，仅适用于手动完成的步骤
+func (c *Client) handleStepComplete(data interface{}) {
+	s, step, ok := c.stepFor(data)
+	if !ok {
+		return
+	}
+	if step.Criteria.Type != models.CriteriaManual {
+		c.recordMistake()
+		c.sendErrorResponse(e.ErrStepCriteriaNotMet)
+		return
+	}
+	c.completeStep(s, step)
+}
+
+// handleStepSkip 处理跳过步骤消息，教学模式下学生不能自行跳过
+func (c *Client) handleStepSkip(data interface{}) {
+	if c.hub.teaching() && c.user.Role == models.Student {
+		c.sendErrorResponse(e.ErrPermissionDenied)
+		return
+	}
+
+	s, step, ok := c.stepFor(data)
+	if !ok {
+		return
+	}
+	s.SkipStep(step.ID, c.hub.nextStepAfter(s.Course, step.ID))
+	c.publishStepState(s)
+	c.hub.invokeScript(script.HookStep, c.user.ID, "skip", step.ID)
+}
+
+// handleStepAdvance 处理教学模式下教师推进全班步骤消息，stepId 为空时推进到下一步
+func (c *Client) handleStepAdvance(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req stepRequest
+	if err := decodeData(data, &req); err != nil || !c.hub.teaching() {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	courseID, _ := c.hub.courseDetail.Course()
+	course, found := c.hub.courses.GetCourse(courseID)
+	if !found {
+		c.sendErrorResponse(e.ErrCourseNotFound)
+		return
+	}
+
+	var step models.Step
+	if req.StepID == "" {
+		step, found = course.NextStep(c.hub.steps.get())
+	} else if index := course.StepIndex(req.StepID); index >= 0 {
+		step, found = course.Steps[index], true
+	} else {
+		found = false
+	}
+	if !found {
+		c.sendErrorResponse(e.ErrStepNotFound)
+		return
+	}
+
+	c.hub.steps.set(step.ID)
+	for _, student := range c.hub.clientsWhere(isStudent) {
+		if s := student.ensureSession(); s != nil {
+			s.StartStep(step.ID)
+		}
+	}
+
+	c.hub.logger.Info("Class advanced to step",
+		zap.String("teacher", c.user.ID),
+		zap.String("courseID", courseID),
+		zap.String("stepID", step.ID))
+
+	response := protocol.Message{
+		Type:       protocol.StepAdvance,
+		DeviceCode: c.user.ID,
+		Data:       stepAdvanceMessage{StepID: step.ID, Title: step.Title},
+	}
+	c.hub.sendTo(allClients, marshalMessage(response))
+	c.hub.invokeScript(script.HookStep, c.user.ID, "advance", step.ID)
+}
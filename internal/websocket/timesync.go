@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"sync"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/utils"
+)
+
+const (
+	clockSampleSize = 8  // 每个客户端保留的时间同步采样数
+	maxPendingSyncs = 16 // 等待客户端确认的时间同步请求上限
+)
+
+// timeSyncRequest 客户端时间同步请求
+// Seq 从 1 开始递增，AckSeq/AckTime 为上一次响应的序号及客户端收到该响应的时间，用于服务器端估算
+type timeSyncRequest struct {
+	Seq        int64 `json:"seq"`
+	ClientTime int64 `json:"clientTime"`
+	AckSeq     int64 `json:"ackSeq"`
+	AckTime    int64 `json:"ackTime"`
+}
+
+// timeSyncResponse 服务器时间同步响应
+type timeSyncResponse struct {
+	Seq               int64 `json:"seq"`
+	ClientTime        int64 `json:"clientTime"`
+	ServerReceiveTime int64 `json:"serverReceiveTime"`
+	ServerSendTime    int64 `json:"serverSendTime"`
+	Offset            int64 `json:"offset"`
+	RTT               int64 `json:"rtt"`
+	Samples           int   `json:"samples"`
+}
+
+// pendingSync 已响应但尚未被客户端确认的时间同步请求
+type pendingSync struct {
+	clientTime  int64
+	receiveTime int64
+	sendTime    int64
+}
+
+// clockSync 保存单个客户端的时间同步状态
+type clockSync struct {
+	estimator *utils.ClockEstimator
+	pending   map[int64]pendingSync
+	mu        sync.Mutex
+}
+
+func newClockSync() *clockSync {
+	return &clockSync{
+		estimator: utils.NewClockEstimator(clockSampleSize),
+		pending:   make(map[int64]pendingSync),
+	}
+}
+
+// ack 根据客户端确认的接收时间生成一次采样
+func (cs *clockSync) ack(seq int64, clientReceiveTime int64) {
+	cs.mu.Lock()
+	p, ok := cs.pending[seq]
+	delete(cs.pending, seq)
+	cs.mu.Unlock()
+
+	if ok && clientReceiveTime > 0 {
+		cs.estimator.AddSample(p.clientTime, p.receiveTime, p.sendTime, clientReceiveTime)
+	}
+}
+
+// track 记录已发出的响应，等待客户端确认
+func (cs *clockSync) track(seq int64, p pendingSync) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if len(cs.pending) >= maxPendingSyncs {
+		for k := range cs.pending {
+			delete(cs.pending, k)
+		}
+	}
+	cs.pending[seq] = p
+}
+
+// handleTimeSync 处理时间同步消息
+func (c *Client) handleTimeSync(data interface{}) {
+	receiveTime := utils.NowMillis()
+
+	var req timeSyncRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	if req.AckSeq > 0 {
+		c.clock.ack(req.AckSeq, req.AckTime)
+	}
+
+	offset, rtt, _ := c.clock.estimator.Estimate()
+	resp := timeSyncResponse{
+		Seq:               req.Seq,
+		ClientTime:        req.ClientTime,
+		ServerReceiveTime: receiveTime,
+		Offset:            offset,
+		RTT:               rtt,
+		Samples:           c.clock.estimator.Count(),
+	}
+	resp.ServerSendTime = utils.NowMillis()
+
+	c.clock.track(req.Seq, pendingSync{
+		clientTime:  req.ClientTime,
+		receiveTime: resp.ServerReceiveTime,
+		sendTime:    resp.ServerSendTime,
+	})
+
+	response := protocol.Message{
+		Type:       protocol.TimeSyncResponse,
+		Data:       resp,
+		ServerTime: resp.ServerSendTime,
+	}
+	c.sendMessage(response)
+}
@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"math"
+
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// maxValueDepth 操作数据允许的最大嵌套深度
+const maxValueDepth = 16
+
+// finiteValue 判断 JSON 值中的数字均为有限值，且嵌套深度不超过限制
+func finiteValue(v interface{}, depth int) bool {
+	if depth > maxValueDepth {
+		return false
+	}
+	switch val := v.(type) {
+	case float64:
+		return !math.IsNaN(val) && !math.IsInf(val, 0)
+	case []interface{}:
+		for _, item := range val {
+			if !finiteValue(item, depth+1) {
+				return false
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range val {
+			if !finiteValue(item, depth+1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// numbers 将 JSON 数组转换为数字切片，长度不符或包含非数字时返回 false
+func numbers(v interface{}, lengths ...int) ([]float64, bool) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	valid := false
+	for _, n := range lengths {
+		valid = valid || len(items) == n
+	}
+	if !valid {
+		return nil, false
+	}
+
+	result := make([]float64, len(items))
+	for i, item := range items {
+		if result[i], ok = item.(float64); !ok {
+			return nil, false
+		}
+	}
+	return result, true
+}
+
+// limit 将 v 限制在 [lower, upper]，超出范围时按 clamp 截断或返回 false
+func limit(v []float64, lower func(int) float64, upper func(int) float64, clamp bool) bool {
+	for i := range v {
+		lo, hi := lower(i), upper(i)
+		if v[i] >= lo && v[i] <= hi {
+			continue
+		}
+		if !clamp {
+			return false
+		}
+		v[i] = math.Max(lo, math.Min(hi, v[i]))
+	}
+	return true
+}
+
+// toJSON 将数字切片转换回 JSON 数组
+func toJSON(v []float64) []interface{} {
+	result := make([]interface{}, len(v))
+	for i := range v {
+		result[i] = v[i]
+	}
+	return result
+}
+
+// checkTransform 校验并规范化操作数据中的变换字段
+func checkTransform(manifest models.ObjectManifest, fields map[string]interface{}) (e.ErrorMessage, bool) {
+	unbounded := func(int) float64 { return models.MaxCoordinate }
+	negUnbounded := func(int) float64 { return -models.MaxCoordinate }
+
+	if raw, ok := fields[models.FieldPosition]; ok {
+		position, ok := numbers(raw, 3)
+		if !ok {
+			return e.ErrInvalidData, false
+		}
+		lower, upper := negUnbounded, unbounded
+		if manifest.Min != nil {
+			lower = func(i int) float64 { return manifest.Min[i] }
+		}
+		if manifest.Max != nil {
+			upper = func(i int) float64 { return manifest.Max[i] }
+		}
+		if !limit(position, lower, upper, manifest.Clamp) {
+			return e.ErrValueOutOfRange, false
+		}
+		fields[models.FieldPosition] = toJSON(position)
+	}
+
+	if raw, ok := fields[models.FieldRotation]; ok {
+		rotation, ok := numbers(raw, 4)
+		if !ok {
+			return e.ErrInvalidData, false
+		}
+		// 四元数归一化，长度为 0 的四元数无法表示旋转
+		norm := math.Sqrt(rotation[0]*rotation[0] + rotation[1]*rotation[1] + rotation[2]*rotation[2] + rotation[3]*rotation[3])
+		if norm < 1e-6 {
+			return e.ErrValueOutOfRange, false
+		}
+		for i := range rotation {
+			rotation[i] /= norm
+		}
+		fields[models.FieldRotation] = toJSON(rotation)
+	}
+
+	if raw, ok := fields[models.FieldScale]; ok {
+		scale, uniform := []float64{0}, true
+		if n, ok := raw.(float64); ok {
+			scale[0] = n
+		} else if scale, ok = numbers(raw, 3); ok {
+			uniform = false
+		} else {
+			return e.ErrInvalidData, false
+		}
+		lower, upper := negUnbounded, unbounded
+		if manifest.MinScale > 0 {
+			lower = func(int) float64 { return manifest.MinScale }
+		}
+		if manifest.MaxScale > 0 {
+			upper = func(int) float64 { return manifest.MaxScale }
+		}
+		if !limit(scale, lower, upper, manifest.Clamp) {
+			return e.ErrValueOutOfRange, false
+		}
+		if uniform {
+			fields[models.FieldScale] = scale[0]
+		} else {
+			fields[models.FieldScale] = toJSON(scale)
+		}
+	}
+	return e.ErrorMessage{}, true
+}
+
+// objectAllowed 判断课程是否允许操作对象：未声明对象清单时不限制，否则只允许清单中的对象和场景中运行时生成的对象
+func (h *Hub) objectAllowed(course *models.Course, scene string, objectID int32) (models.ObjectManifest, bool) {
+	if manifest, ok := course.ObjectManifest(objectID); ok {
+		return manifest, true
+	}
+	if len(course.Objects) == 0 {
+		return models.ObjectManifest{ID: objectID}, true
+	}
+	if _, ok := h.sceneDetail(scene).Object(objectID); ok {
+		return models.ObjectManifest{ID: objectID}, true
+	}
+	return models.ObjectManifest{}, false
+}
+
+// checkManipulation 校验单个对象的操作数据，返回规范化后的数据
+func (h *Hub) checkManipulation(course *models.Course, scene string, objectID int32, value interface{}) (interface{}, e.ErrorMessage, bool) {
+	if !finiteValue(value, 0) {
+		return nil, e.ErrInvalidValue, false
+	}
+	if course == nil {
+		return value, e.ErrorMessage{}, true
+	}
+
+	manifest, ok := h.objectAllowed(course, scene, objectID)
+	if !ok {
+		return nil, e.ErrObjectNotAllowed, false
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return value, e.ErrorMessage{}, true
+	}
+	for name := range fields {
+		if !manifest.Allows(name) {
+			return nil, e.ErrObjectNotAllowed, false
+		}
+	}
+	if reason, ok := checkTransform(manifest, fields); !ok {
+		return nil, reason, false
+	}
+	return fields, e.ErrorMessage{}, true
+}
+
+// isMistake 判断被拒绝的操作是否属于学生的操作失误（超出允许范围、操作不允许的对象），数据格式错误不计入
+func isMistake(reason e.ErrorMessage) bool {
+	return reason.Code == e.ErrValueOutOfRange.Code || reason.Code == e.ErrObjectNotAllowed.Code
+}
+
+// validateManipulation 按课程对象清单校验操作数据，丢弃不合法的对象并记录发送者
+// 返回可以应用的数据；有对象被拒绝时向发送者返回第一个错误
+func (c *Client) validateManipulation(scene string, data map[int32]interface{}) map[int32]interface{} {
+	courseID, _ := c.hub.courseDetail.Course()
+	course, _ := c.hub.courses.GetCourse(courseID)
+
+	var first *e.ErrorMessage
+	mistake := false
+	for objectID, value := range data {
+		checked, reason, ok := c.hub.checkManipulation(course, scene, objectID, value)
+		if ok {
+			data[objectID] = checked
+			continue
+		}
+
+		delete(data, objectID)
+		c.hub.logger.Warn("Rejected object manipulation",
+			zap.String("deviceCode", c.user.ID),
+			zap.String("remoteAddr", c.conn.RemoteAddr().String()),
+			zap.Int32("objectID", objectID),
+			zap.String("reason", reason.Message))
+		if first == nil {
+			first = &reason
+		}
+		mistake = mistake || isMistake(reason)
+	}
+
+	if mistake {
+		c.recordMistake()
+	}
+	if first != nil {
+		c.sendErrorResponse(*first)
+	}
+	return data
+}
@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"sort"
+
+	"xnfz/api"
+	e "xnfz/internal/errors"
+	"xnfz/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// rtcSignal WebRTC 信令（offer、answer、ICE 候选、挂断），服务器只转发不解析 payload
+type rtcSignal struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	CallID  string      `json:"callId,omitempty"` // 客户端生成的通话标识，用于区分同一对设备间的多个连接
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// voiceTalkRequest 教师按键讲话请求
+type voiceTalkRequest struct {
+	Talking bool `json:"talking"`
+}
+
+// talkers 返回正在讲话的教师列表，调用方需持有锁
+func (cc *classroomControls) talkers() []string {
+	result := make([]string, 0, len(cc.talking))
+	for deviceCode := range cc.talking {
+		result = append(result, deviceCode)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// setTalking 设置教师的讲话状态，返回状态是否变化
+func (cc *classroomControls) setTalking(deviceCode string, talking bool) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.talking[deviceCode] == talking {
+		return false
+	}
+	if talking {
+		cc.talking[deviceCode] = true
+	} else {
+		delete(cc.talking, deviceCode)
+	}
+	return true
+}
+
+// handleRTCSignal 转发 WebRTC 信令给指定客户端
+// 被静音的学生不能发起新的通话（offer），但可以应答和挂断
+func (c *Client) handleRTCSignal(msgType int32, data interface{}) {
+	var signal rtcSignal
+	if err := decodeData(data, &signal); err != nil || signal.To == "" || signal.To == c.user.ID {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+	if msgType == protocol.RTCOffer && !c.requireUnmuted() {
+		return
+	}
+
+	if len(c.hub.clientsByUser(signal.To)) == 0 {
+		c.sendErrorResponse(e.ErrClientNotFound)
+		return
+	}
+
+	signal.From = c.user.ID
+	response := protocol.Message{
+		Type:       msgType,
+		DeviceCode: c.user.ID,
+		Data:       signal,
+	}
+	c.hub.sendTo(func(client *Client) bool {
+		return client.user.ID == signal.To
+	}, marshalMessage(response))
+
+	if msgType == protocol.RTCOffer || msgType == protocol.RTCHangup {
+		c.hub.logger.Debug("RTC signal relayed",
+			zap.Int32("type", msgType),
+			zap.String("from", c.user.ID),
+			zap.String("to", signal.To),
+			zap.String("callID", signal.CallID))
+	}
+}
+
+// handleVoiceTalk 处理教师按键讲话消息，状态通过课堂控制状态推送给所有客户端
+func (c *Client) handleVoiceTalk(data interface{}) {
+	if !c.requireTeacher() {
+		return
+	}
+
+	var req voiceTalkRequest
+	if err := decodeData(data, &req); err != nil {
+		c.sendErrorResponse(e.ErrInvalidData)
+		return
+	}
+
+	if c.hub.classroom.setTalking(c.user.ID, req.Talking) {
+		c.hub.broadcastClassroomState()
+	}
+}
+
+// releaseTalk 教师离线时结束其讲话状态
+func (h *Hub) releaseTalk(client *Client) {
+	if client.user.Role != models.Teacher || len(h.clientsByUser(client.user.ID)) > 0 {
+		return
+	}
+	if h.classroom.setTalking(client.user.ID, false) {
+		h.broadcastClassroomState()
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+// AssetBundle 课程需要的场景或资源包，客户端版本不一致时对象可能无法显示
+type AssetBundle struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	Hash    string `json:"hash,omitempty"` // 文件的 SHA-256（十六进制）
+	Size    int64  `json:"size,omitempty"` // 文件字节数
+	File    string `json:"file,omitempty"` // 相对于资源目录的文件路径，默认为 ID
+	URL     string `json:"url,omitempty"`  // 下载地址，由服务器填写
+}
+
+// Path 返回资源包相对于资源目录的文件路径
+func (b AssetBundle) Path() string {
+	if b.File != "" {
+		return b.File
+	}
+	return b.ID
+}
+
+// FindAsset 按 ID 查找课程资源包
+func (c *Course) FindAsset(bundleID string) (AssetBundle, bool) {
+	for _, bundle := range c.Assets {
+		if bundle.ID == bundleID {
+			return bundle, true
+		}
+	}
+	return AssetBundle{}, false
+}
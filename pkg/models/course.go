@@ -9,10 +9,93 @@ const (
 	PracticeMode
 )
 
+// 步骤完成条件类型
+const (
+	CriteriaManual     = "manual"     // 客户端上报完成
+	CriteriaManipulate = "manipulate" // 操作指定对象后由服务器判定完成
+)
+
+// StepCriteria 步骤完成条件
+type StepCriteria struct {
+	Type      string  `json:"type"`
+	ObjectIDs []int32 `json:"objectIds,omitempty"`
+}
+
+// Step 课程中的一个步骤
+type Step struct {
+	ID       string       `json:"id"`
+	Title    string       `json:"title"`
+	Criteria StepCriteria `json:"criteria"`
+}
+
 type Course struct {
 	ID          string
 	Name        string
 	Description string
 	Mode        CourseMode
 	Duration    time.Duration // Only applicable for PracticeMode
+	Steps       []Step
+	Scoring     *ScoringSpec
+	Faults      []Fault
+	Properties  []PropertySpec
+	Objects     []ObjectManifest // 可操作对象清单，为空表示不限制对象 ID
+	Assets      []AssetBundle    // 课程需要的资源包
+	Prefabs     []string         // 运行时可以生成的预制体 ID，为空表示不限制
+	Script      string           // 课程脚本（Lua 源码），为空表示没有脚本
+}
+
+// StepIndex 返回步骤在课程中的位置，不存在时返回 -1
+func (c *Course) StepIndex(stepID string) int {
+	for i, step := range c.Steps {
+		if step.ID == stepID {
+			return i
+		}
+	}
+	return -1
+}
+
+// NextStep 返回指定步骤之后的步骤，stepID 为空时返回第一个步骤
+func (c *Course) NextStep(stepID string) (Step, bool) {
+	next := 0
+	if stepID != "" {
+		next = c.StepIndex(stepID) + 1
+		if next == 0 {
+			return Step{}, false
+		}
+	}
+	if next >= len(c.Steps) {
+		return Step{}, false
+	}
+	return c.Steps[next], true
+}
+
+// Fault 课程预定义的故障，教师可在课程进行中注入
+type Fault struct {
+	ID        string                 `json:"id"`
+	Title     string                 `json:"title"`
+	ObjectIDs []int32                `json:"objectIds,omitempty"` // 故障作用的对象，学生操作这些对象视为开始处置
+	Params    map[string]interface{} `json:"params,omitempty"`    // 故障默认参数
+}
+
+// HasPrefab 判断课程是否允许生成指定预制体，未声明预制体清单时允许任意预制体
+func (c *Course) HasPrefab(prefabID string) bool {
+	if len(c.Prefabs) == 0 {
+		return true
+	}
+	for _, id := range c.Prefabs {
+		if id == prefabID {
+			return true
+		}
+	}
+	return false
+}
+
+// FindFault 按 ID 查找课程故障
+func (c *Course) FindFault(faultID string) (Fault, bool) {
+	for _, fault := range c.Faults {
+		if fault.ID == faultID {
+			return fault, true
+		}
+	}
+	return Fault{}, false
 }
@@ -0,0 +1,46 @@
+package models
+
+// 对象操作数据中的变换字段
+const (
+	FieldPosition = "position" // 位置 [x, y, z]
+	FieldRotation = "rotation" // 旋转四元数 [x, y, z, w]
+	FieldScale    = "scale"    // 缩放，数字或 [x, y, z]
+)
+
+// MaxCoordinate 未声明范围时位置和缩放分量允许的最大绝对值
+const MaxCoordinate = 1e6
+
+// ObjectManifest 课程中可操作对象的声明
+// 课程声明了对象清单时，只允许操作清单中的对象和运行时生成的对象
+type ObjectManifest struct {
+	ID         int32     `json:"id"`
+	Min        []float64 `json:"min,omitempty"`        // 位置下界 [x, y, z]
+	Max        []float64 `json:"max,omitempty"`        // 位置上界 [x, y, z]
+	MinScale   float64   `json:"minScale,omitempty"`   // 缩放分量下界，0 表示不限
+	MaxScale   float64   `json:"maxScale,omitempty"`   // 缩放分量上界，0 表示不限
+	Properties []string  `json:"properties,omitempty"` // 允许修改的操作字段和自定义属性，为空表示不限
+	Clamp      bool      `json:"clamp,omitempty"`      // 超出范围时截断到边界，否则拒绝
+}
+
+// Allows 判断对象是否允许修改指定字段或属性
+func (m ObjectManifest) Allows(name string) bool {
+	if len(m.Properties) == 0 {
+		return true
+	}
+	for _, allowed := range m.Properties {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectManifest 按 ID 查找对象声明
+func (c *Course) ObjectManifest(objectID int32) (ObjectManifest, bool) {
+	for _, manifest := range c.Objects {
+		if manifest.ID == objectID {
+			return manifest, true
+		}
+	}
+	return ObjectManifest{}, false
+}
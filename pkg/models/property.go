@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// PropertyType 对象自定义属性的类型
+type PropertyType string
+
+const (
+	PropertyBool   PropertyType = "bool"
+	PropertyNumber PropertyType = "number"
+	PropertyString PropertyType = "string"
+	PropertyVector PropertyType = "vector"
+	PropertyEnum   PropertyType = "enum"
+)
+
+// 属性值限制
+const (
+	MaxPropertyString = 256 // 字符串属性的最大字节数
+	MaxVectorLength   = 4   // 向量属性的最大维数
+)
+
+// PropertySpec 课程中对象属性的声明，未声明的属性需要在消息中给出类型
+type PropertySpec struct {
+	ObjectID int32        `json:"objectId"`
+	Name     string       `json:"name"`
+	Type     PropertyType `json:"type"`
+	Values   []string     `json:"values,omitempty"` // enum：可选值
+	Min      *float64     `json:"min,omitempty"`    // number：最小值
+	Max      *float64     `json:"max,omitempty"`    // number：最大值
+	Length   int          `json:"length,omitempty"` // vector：维数，0 表示 2 到 4 维均可
+}
+
+// Valid 判断属性类型是否合法
+func (t PropertyType) Valid() bool {
+	switch t {
+	case PropertyBool, PropertyNumber, PropertyString, PropertyVector, PropertyEnum:
+		return true
+	}
+	return false
+}
+
+// Normalize 校验 JSON 解码后的属性值并转换为规范形式（数字为 float64，向量为 []float64）
+func (p PropertySpec) Normalize(value interface{}) (interface{}, error) {
+	switch p.Type {
+	case PropertyBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+
+	case PropertyNumber:
+		v, ok := value.(float64)
+		if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+			break
+		}
+		if (p.Min != nil && v < *p.Min) || (p.Max != nil && v > *p.Max) {
+			return nil, fmt.Errorf("%s out of range", p.Name)
+		}
+		return v, nil
+
+	case PropertyString:
+		if v, ok := value.(string); ok && len(v) <= MaxPropertyString {
+			return v, nil
+		}
+
+	case PropertyVector:
+		items, ok := value.([]interface{})
+		if !ok || len(items) < 2 || len(items) > MaxVectorLength || (p.Length > 0 && len(items) != p.Length) {
+			break
+		}
+		vector := make([]float64, len(items))
+		for i, item := range items {
+			v, ok := item.(float64)
+			if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("invalid %s value", p.Name)
+			}
+			vector[i] = v
+		}
+		return vector, nil
+
+	case PropertyEnum:
+		v, ok := value.(string)
+		if !ok {
+			break
+		}
+		for _, allowed := range p.Values {
+			if v == allowed {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not one of %v", p.Name, p.Values)
+	}
+
+	return nil, fmt.Errorf("invalid %s value for %s", p.Type, p.Name)
+}
+
+// PropertySpec 返回课程中对象属性的声明
+func (c *Course) PropertySpec(objectID int32, name string) (PropertySpec, bool) {
+	for _, spec := range c.Properties {
+		if spec.ObjectID == objectID && spec.Name == name {
+			return spec, true
+		}
+	}
+	return PropertySpec{}, false
+}
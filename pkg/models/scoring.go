@@ -0,0 +1,32 @@
+package models
+
+// 评分规则类型
+const (
+	RuleOrder        = "order"         // 步骤或对象需按指定顺序完成/操作
+	RuleTimeLimit    = "time_limit"    // 整个练习或单个步骤需在限定时间内完成
+	RuleStepComplete = "step_complete" // 完成指定步骤得分
+	RulePenalty      = "penalty"       // 每次发生指定事件扣分
+	RuleProperty     = "property"      // 对象属性被设置为指定值时得分
+)
+
+// ScoringRule 一条声明式评分规则
+type ScoringRule struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Points    int         `json:"points"`              // 满足规则得分，或扣分规则每次扣除的分数
+	Steps     []string    `json:"steps,omitempty"`     // order：步骤顺序
+	Objects   []int32     `json:"objects,omitempty"`   // order：对象首次操作顺序；penalty：禁止操作的对象
+	StepID    string      `json:"stepId,omitempty"`    // time_limit / step_complete 针对的步骤
+	Seconds   int         `json:"seconds,omitempty"`   // time_limit：限定时间
+	Event     string      `json:"event,omitempty"`     // penalty：扣分事件（error、skip、manipulate）
+	MaxPoints int         `json:"maxPoints,omitempty"` // penalty：最多扣除的分数，0 表示不限
+	ObjectID  int32       `json:"objectId,omitempty"`  // property：对象
+	Property  string      `json:"property,omitempty"`  // property：属性名
+	Value     interface{} `json:"value,omitempty"`     // property：目标值
+}
+
+// ScoringSpec 课程的评分配置
+type ScoringSpec struct {
+	MaxScore int           `json:"maxScore"` // 满分，0 表示取所有得分规则之和
+	Rules    []ScoringRule `json:"rules"`
+}
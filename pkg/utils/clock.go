@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// NowMillis 返回当前服务器时间（Unix 毫秒）
+func NowMillis() int64 {
+	return time.Now().UnixMilli()
+}
+
+// ClockSample 一次 NTP 式时间同步采样（毫秒）
+// Offset 为服务器时间减去客户端时间，RTT 为往返网络延迟
+type ClockSample struct {
+	Offset int64
+	RTT    int64
+}
+
+// ClockEstimator 根据多次采样估算客户端时钟偏移和往返延迟
+type ClockEstimator struct {
+	samples []ClockSample
+	size    int
+	mu      sync.RWMutex
+}
+
+func NewClockEstimator(size int) *ClockEstimator {
+	if size <= 0 {
+		size = 8
+	}
+	return &ClockEstimator{
+		samples: make([]ClockSample, 0, size),
+		size:    size,
+	}
+}
+
+// AddSample 根据四个时间戳计算一次采样并保存
+// t0 客户端发送，t1 服务器接收，t2 服务器发送，t3 客户端接收
+func (e *ClockEstimator) AddSample(t0, t1, t2, t3 int64) ClockSample {
+	sample := ClockSample{
+		Offset: ((t1 - t0) + (t2 - t3)) / 2,
+		RTT:    (t3 - t0) - (t2 - t1),
+	}
+	if sample.RTT < 0 {
+		sample.RTT = 0
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.samples) == e.size {
+		e.samples = e.samples[1:]
+	}
+	e.samples = append(e.samples, sample)
+	return sample
+}
+
+// Estimate 返回估算结果：偏移取 RTT 最小的一半采样的平均值，RTT 取中位数
+func (e *ClockEstimator) Estimate() (offset int64, rtt int64, ok bool) {
+	e.mu.RLock()
+	sorted := make([]ClockSample, len(e.samples))
+	copy(sorted, e.samples)
+	e.mu.RUnlock()
+
+	if len(sorted) == 0 {
+		return 0, 0, false
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].RTT < sorted[j].RTT
+	})
+
+	best := sorted[:(len(sorted)+1)/2]
+	var sum int64
+	for _, s := range best {
+		sum += s.Offset
+	}
+
+	return sum / int64(len(best)), sorted[len(sorted)/2].RTT, true
+}
+
+// Count 返回当前保存的采样数
+func (e *ClockEstimator) Count() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.samples)
+}
@@ -0,0 +1,109 @@
+package utils
+
+import "testing"
+
+// exchange 一次时间同步：服务器时钟比客户端快 offset，上行和下行网络延迟分别为 up、down
+type exchange struct {
+	offset, up, down int64
+}
+
+func TestClockEstimator(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		exchanges  []exchange
+		wantOffset int64
+		wantRTT    int64
+		wantOK     bool
+	}{
+		{
+			name: "no samples",
+		},
+		{
+			name:       "symmetric delay",
+			exchanges:  []exchange{{offset: 500, up: 10, down: 10}},
+			wantOffset: 500,
+			wantRTT:    20,
+			wantOK:     true,
+		},
+		{
+			name:       "asymmetric delay skews offset by half the difference",
+			exchanges:  []exchange{{offset: 500, up: 30, down: 10}},
+			wantOffset: 510,
+			wantRTT:    40,
+			wantOK:     true,
+		},
+		{
+			name:       "client behind server",
+			exchanges:  []exchange{{offset: -1200, up: 5, down: 5}},
+			wantOffset: -1200,
+			wantRTT:    10,
+			wantOK:     true,
+		},
+		{
+			name: "slow sample excluded from offset",
+			exchanges: []exchange{
+				{offset: 100, up: 5, down: 5},
+				{offset: 100, up: 6, down: 6},
+				{offset: 100, up: 4, down: 4},
+				{offset: 100, up: 200, down: 0},
+			},
+			wantOffset: 100,
+			wantRTT:    12,
+			wantOK:     true,
+		},
+		{
+			name: "offset averages fastest half",
+			exchanges: []exchange{
+				{offset: 90, up: 1, down: 1},
+				{offset: 110, up: 2, down: 2},
+				{offset: 300, up: 50, down: 50},
+			},
+			wantOffset: 100,
+			wantRTT:    4,
+			wantOK:     true,
+		},
+		{
+			name: "oldest sample evicted",
+			size: 2,
+			exchanges: []exchange{
+				{offset: 1000, up: 1, down: 1},
+				{offset: 50, up: 10, down: 10},
+				{offset: 60, up: 5, down: 5},
+			},
+			wantOffset: 60,
+			wantRTT:    20,
+			wantOK:     true,
+		},
+		{
+			name:       "negative rtt clamped",
+			exchanges:  []exchange{{offset: 500, up: -5, down: 0}},
+			wantOffset: 497,
+			wantRTT:    0,
+			wantOK:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewClockEstimator(tt.size)
+			for _, ex := range tt.exchanges {
+				const processing = 3
+				t0 := int64(10000)
+				t1 := t0 + ex.offset + ex.up
+				t2 := t1 + processing
+				t3 := t2 - ex.offset + ex.down
+				e.AddSample(t0, t1, t2, t3)
+			}
+
+			offset, rtt, ok := e.Estimate()
+			if offset != tt.wantOffset || rtt != tt.wantRTT || ok != tt.wantOK {
+				t.Errorf("Estimate() = (%d, %d, %v), want (%d, %d, %v)",
+					offset, rtt, ok, tt.wantOffset, tt.wantRTT, tt.wantOK)
+			}
+			if want := min(len(tt.exchanges), e.size); e.Count() != want {
+				t.Errorf("Count() = %d, want %d", e.Count(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions 比较点分数字版本号（如 1.4.2），返回 -1、0 或 1
+// 缺少的段视为 0，非数字段视为 0；构建后缀（+ 之后的部分）被忽略
+// 预发布版本（如 1.4.2-beta.1）低于对应的正式版本，预发布标识按语义化版本规则比较
+func CompareVersions(a string, b string) int {
+	ra, pa := splitVersion(a)
+	rb, pb := splitVersion(b)
+	if c := compareRelease(versionParts(ra), versionParts(rb)); c != 0 {
+		return c
+	}
+	return comparePrerelease(pa, pb)
+}
+
+// splitVersion 去掉前缀 v 和构建后缀，拆分为正式版本号和预发布标识
+func splitVersion(v string) (release string, prerelease string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	release, prerelease, _ = strings.Cut(v, "-")
+	return release, prerelease
+}
+
+// compareRelease 逐段比较数字版本号
+func compareRelease(pa []int, pb []int) int {
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return compareInt(x, y)
+		}
+	}
+	return 0
+}
+
+// comparePrerelease 比较预发布标识，没有预发布标识的版本更高
+// 数字标识按数值比较且低于非数字标识，标识相同时段数多的更高
+func comparePrerelease(a string, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	fa, fb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		x, errX := strconv.Atoi(fa[i])
+		y, errY := strconv.Atoi(fb[i])
+		switch {
+		case errX == nil && errY == nil:
+			if x != y {
+				return compareInt(x, y)
+			}
+		case errX == nil:
+			return -1
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(fa[i], fb[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(fa), len(fb))
+}
+
+// compareInt 比较两个整数，返回 -1、0 或 1
+func compareInt(x int, y int) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// versionParts 将正式版本号拆分为数字段
+func versionParts(v string) []int {
+	if v == "" {
+		return nil
+	}
+
+	fields := strings.Split(v, ".")
+	parts := make([]int, len(fields))
+	for i, field := range fields {
+		parts[i], _ = strconv.Atoi(field)
+	}
+	return parts
+}
@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.4.2", "1.4.2", 0},
+		{"1.4.2", "1.4.10", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"1.4", "1.4.0", 0},
+		{"1.4", "1.4.1", -1},
+		{"v2.0.0", "2.0.0", 0},
+		{" 1.0.0 ", "1.0.0", 0},
+		{"1.x.0", "1.0.0", 0},
+		{"", "0.0.0", 0},
+		{"", "0.0.1", -1},
+		{"1.0.0+build.5", "1.0.0+build.9", 0},
+		{"1.0.0+build", "1.0.0-rc.1", 1},
+
+		// 预发布版本
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.0-rc.1", "0.9.9", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta", "1.0.0-beta.2", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.1+build.2", 0},
+		{"1.0-rc.1", "1.0.0-rc.1", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}